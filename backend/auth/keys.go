@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"main/config"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+}
+
+type keySet struct {
+	signingMethod jwt.SigningMethod
+	signingKey    crypto.PrivateKey
+	signingKid    string
+	verification  map[string]verificationKey
+	hmacSecret    []byte
+}
+
+var keys *keySet
+
+// LoadKeys reads the signing and verification keys named in the config. When
+// no signing key is configured, tokens are signed with the HMAC secret.
+// The HMAC secret, if set, stays accepted for verification so tokens issued
+// before a switch to asymmetric keys keep working until they expire.
+func LoadKeys(cfg *config.Config) error {
+	set := &keySet{verification: map[string]verificationKey{}}
+
+	if cfg.JWTSecret != "" {
+		set.hmacSecret = []byte(cfg.JWTSecret)
+	}
+
+	if cfg.JWTSigningKey != "" {
+		private, err := readPrivateKey(cfg.JWTSigningKey)
+		if err != nil {
+			return err
+		}
+
+		method, public, err := methodFor(private)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", cfg.JWTSigningKey, err)
+		}
+
+		kid, err := thumbprint(public)
+		if err != nil {
+			return err
+		}
+
+		set.signingMethod = method
+		set.signingKey = private
+		set.signingKid = kid
+		set.verification[kid] = verificationKey{method: method, key: public}
+	} else {
+		set.signingMethod = jwt.SigningMethodHS256
+	}
+
+	for _, path := range cfg.JWTVerificationKeys {
+		public, err := readPublicKey(path)
+		if err != nil {
+			return err
+		}
+
+		method, err := publicMethodFor(public)
+		if err != nil {
+			return fmt.Errorf("verification key %s: %w", path, err)
+		}
+
+		kid, err := thumbprint(public)
+		if err != nil {
+			return err
+		}
+		set.verification[kid] = verificationKey{method: method, key: public}
+	}
+
+	keys = set
+	return nil
+}
+
+func sign(claims jwt.MapClaims) (string, error) {
+	if keys == nil {
+		return "", errors.New("signing keys have not been loaded")
+	}
+
+	token := jwt.NewWithClaims(keys.signingMethod, claims)
+	if keys.signingKey == nil {
+		return token.SignedString(keys.hmacSecret)
+	}
+
+	token.Header["kid"] = keys.signingKid
+	return token.SignedString(keys.signingKey)
+}
+
+func verificationKeyFor(token *jwt.Token) (interface{}, error) {
+	if keys == nil {
+		return nil, errors.New("signing keys have not been loaded")
+	}
+
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if keys.hmacSecret == nil {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return keys.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keys.verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.key, nil
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set.
+func JWKS() map[string]interface{} {
+	jwks := []map[string]string{}
+	if keys != nil {
+		for kid, key := range keys.verification {
+			jwk, err := toJWK(key.key)
+			if err != nil {
+				continue
+			}
+			jwk["kid"] = kid
+			jwk["use"] = "sig"
+			jwk["alg"] = key.method.Alg()
+			jwks = append(jwks, jwk)
+		}
+	}
+	return map[string]interface{}{"keys": jwks}
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key %s: %w", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s is not PEM encoded", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key %s is not a PKCS#8 or PKCS#1 private key", path)
+}
+
+// readPublicKey accepts either a public key or a private key, so the key that
+// was just rotated out can be listed as-is.
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	private, err := readPrivateKey(path)
+	if err != nil {
+		return nil, fmt.Errorf("key %s is not a supported public or private key", path)
+	}
+	_, public, err := methodFor(private)
+	return public, err
+}
+
+func methodFor(private crypto.PrivateKey) (jwt.SigningMethod, crypto.PublicKey, error) {
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, &key.PublicKey, nil
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, key.Public(), nil
+	}
+	return nil, nil, errors.New("only RSA and Ed25519 keys are supported")
+}
+
+func publicMethodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, errors.New("only RSA and Ed25519 keys are supported")
+}
+
+func toJWK(public crypto.PublicKey) (map[string]string, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	}
+	return nil, errors.New("only RSA and Ed25519 keys are supported")
+}
+
+// thumbprint derives the key ID from the RFC 7638 JWK thumbprint, so the same
+// key always gets the same kid on every server.
+func thumbprint(public crypto.PublicKey) (string, error) {
+	jwk, err := toJWK(public)
+	if err != nil {
+		return "", err
+	}
+
+	// json.Marshal sorts map keys, which is the member order RFC 7638 requires.
+	data, err := json.Marshal(jwk)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
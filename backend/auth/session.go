@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"main/database"
+	model "main/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const RefreshTokenLifetime = time.Hour * 24 * 30
+
+var (
+	ErrSessionRevoked      = errors.New("session has been revoked")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+// CreateSession opens a new session for the principal and returns it with
+// its first refresh token.
+func CreateSession(id uint, typ int, userAgent string, ip string) (model.Session, string, error) {
+	db := database.GetInstance()
+
+	secret, err := randomToken()
+	if err != nil {
+		return model.Session{}, "", err
+	}
+
+	now := time.Now()
+	session := model.Session{
+		PrincipalID:      id,
+		PrincipalType:    typ,
+		RefreshTokenHash: hashToken(secret),
+		UserAgent:        userAgent,
+		IPAddress:        ip,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(RefreshTokenLifetime),
+	}
+
+	if err := db.Create(&session).Error; err != nil {
+		return model.Session{}, "", err
+	}
+
+	return session, formatRefreshToken(session.ID, secret), nil
+}
+
+// RotateSession exchanges a refresh token for a new one. Presenting a token
+// that was already rotated away revokes the whole session, since it means the
+// token has been copied.
+func RotateSession(refreshToken string, userAgent string, ip string) (model.Session, string, error) {
+	db := database.GetInstance()
+
+	sessionID, secret, err := parseRefreshToken(refreshToken)
+	if err != nil {
+		return model.Session{}, "", err
+	}
+
+	var session model.Session
+	if err := db.First(&session, sessionID).Error; err != nil {
+		return model.Session{}, "", ErrInvalidRefreshToken
+	}
+
+	if !session.IsActive() {
+		return model.Session{}, "", ErrSessionRevoked
+	}
+
+	if session.RefreshTokenHash != hashToken(secret) {
+		RevokeSession(session.ID)
+		return model.Session{}, "", ErrSessionRevoked
+	}
+
+	newSecret, err := randomToken()
+	if err != nil {
+		return model.Session{}, "", err
+	}
+
+	result := db.Model(&model.Session{}).
+		Where("id = ? AND generation = ?", session.ID, session.Generation).
+		Updates(map[string]interface{}{
+			"refresh_token_hash": hashToken(newSecret),
+			"generation":         session.Generation + 1,
+			"user_agent":         userAgent,
+			"ip_address":         ip,
+			"last_used_at":       time.Now(),
+		})
+	if result.Error != nil {
+		return model.Session{}, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.Session{}, "", ErrSessionRevoked
+	}
+
+	db.First(&session, session.ID)
+
+	return session, formatRefreshToken(session.ID, newSecret), nil
+}
+
+func RevokeSession(sessionID uint) error {
+	db := database.GetInstance()
+
+	return db.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllSessions signs the principal out everywhere.
+func RevokeAllSessions(tx *gorm.DB, id uint, typ int) error {
+	return tx.Model(&model.Session{}).
+		Where("principal_id = ? AND principal_type = ? AND revoked_at IS NULL", id, typ).
+		Update("revoked_at", time.Now()).Error
+}
+
+// SessionFromRefreshToken looks up the session a refresh token belongs to
+// without rotating it.
+func SessionFromRefreshToken(refreshToken string) (model.Session, error) {
+	db := database.GetInstance()
+
+	sessionID, secret, err := parseRefreshToken(refreshToken)
+	if err != nil {
+		return model.Session{}, err
+	}
+
+	var session model.Session
+	if err := db.First(&session, sessionID).Error; err != nil {
+		return model.Session{}, ErrInvalidRefreshToken
+	}
+
+	if session.RefreshTokenHash != hashToken(secret) {
+		return model.Session{}, ErrInvalidRefreshToken
+	}
+
+	return session, nil
+}
+
+// ValidateSession rejects access tokens whose session was revoked or rotated
+// after the token was issued.
+func ValidateSession(principal Principal) error {
+	db := database.GetInstance()
+
+	var session model.Session
+	if err := db.First(&session, principal.SessionID).Error; err != nil {
+		return ErrSessionRevoked
+	}
+
+	if !session.IsActive() || session.Generation != principal.Generation ||
+		session.PrincipalID != principal.ID || session.PrincipalType != principal.Type {
+		return ErrSessionRevoked
+	}
+
+	return nil
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func formatRefreshToken(sessionID uint, secret string) string {
+	return fmt.Sprintf("%d.%s", sessionID, secret)
+}
+
+func parseRefreshToken(refreshToken string) (uint, string, error) {
+	id, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || secret == "" {
+		return 0, "", ErrInvalidRefreshToken
+	}
+
+	sessionID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, "", ErrInvalidRefreshToken
+	}
+
+	return uint(sessionID), secret, nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"fmt"
+	"main/database"
+	model "main/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type throttlePolicy struct {
+	freeAttempts int
+	baseLockout  time.Duration
+	maxLockout   time.Duration
+}
+
+var (
+	// Accounts are locked quickly; IPs get more room because many users can
+	// share one address behind a NAT.
+	accountPolicy = throttlePolicy{freeAttempts: 5, baseLockout: 30 * time.Second, maxLockout: time.Hour}
+	ipPolicy      = throttlePolicy{freeAttempts: 20, baseLockout: time.Minute, maxLockout: time.Hour}
+)
+
+// Failures older than this no longer count towards a lockout.
+const failureWindow = time.Hour * 24
+
+func AccountSubject(typ int, email string) string {
+	return TypeName(typ) + ":" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// TwoFactorSubject counts wrong second-factor codes for an account that has
+// already passed the password check.
+func TwoFactorSubject(typ int, id uint) string {
+	return fmt.Sprintf("2fa:%s:%d", TypeName(typ), id)
+}
+
+func IPSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginLockedFor returns how long the account or IP must wait before trying
+// again, or zero if a login attempt is allowed.
+func LoginLockedFor(typ int, email string, ip string) time.Duration {
+	db := database.GetInstance()
+	now := time.Now()
+
+	var throttles []model.LoginThrottle
+	db.Where("subject IN ? AND locked_until > ?", []string{AccountSubject(typ, email), IPSubject(ip)}, now).Find(&throttles)
+
+	var wait time.Duration
+	for _, throttle := range throttles {
+		if remaining := throttle.LockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait
+}
+
+// TwoFactorLockedFor is LoginLockedFor for the second login step.
+func TwoFactorLockedFor(typ int, id uint) time.Duration {
+	db := database.GetInstance()
+	now := time.Now()
+
+	var throttle model.LoginThrottle
+	if db.Where("subject = ? AND locked_until > ?", TwoFactorSubject(typ, id), now).Limit(1).Find(&throttle).RowsAffected == 0 {
+		return 0
+	}
+	return throttle.LockedUntil.Sub(now)
+}
+
+func RecordTwoFactorFailure(typ int, id uint) error {
+	return recordFailure(TwoFactorSubject(typ, id), accountPolicy)
+}
+
+// RecordLoginFailure counts a failed attempt against both the account and
+// the IP, doubling the lockout with every failure past the free attempts.
+func RecordLoginFailure(typ int, email string, ip string) error {
+	if err := recordFailure(AccountSubject(typ, email), accountPolicy); err != nil {
+		return err
+	}
+	return recordFailure(IPSubject(ip), ipPolicy)
+}
+
+// RecordLoginSuccess clears the account's failures. The IP counter is left
+// alone so one valid login can't reset guessing against other accounts.
+func RecordLoginSuccess(typ int, email string) error {
+	return UnlockLogin(AccountSubject(typ, email))
+}
+
+func UnlockLogin(subject string) error {
+	db := database.GetInstance()
+	return db.Where("subject = ?", subject).Delete(&model.LoginThrottle{}).Error
+}
+
+func recordFailure(subject string, policy throttlePolicy) error {
+	db := database.GetInstance()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var throttle model.LoginThrottle
+		if tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("subject = ?", subject).Limit(1).Find(&throttle).RowsAffected == 0 {
+			throttle = model.LoginThrottle{Subject: subject}
+		}
+
+		if now.Sub(throttle.LastFailureAt) > failureWindow {
+			throttle.Failures = 0
+		}
+
+		throttle.Failures++
+		throttle.LastFailureAt = now
+
+		if throttle.Failures >= policy.freeAttempts {
+			lockout := policy.maxLockout
+			if shift := throttle.Failures - policy.freeAttempts; shift < 16 {
+				if backoff := policy.baseLockout << shift; backoff < lockout {
+					lockout = backoff
+				}
+			}
+			until := now.Add(lockout)
+			throttle.LockedUntil = &until
+		}
+
+		return tx.Save(&throttle).Error
+	})
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"errors"
+	model "main/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	TypeUser   = 0
+	TypeTailor = 1
+	TypeAdmin  = 2
+)
+
+const (
+	AccessTokenLifetime = time.Minute * 15
+	MFATokenLifetime    = time.Minute * 5
+)
+
+func TypeName(typ int) string {
+	switch typ {
+	case TypeUser:
+		return "user"
+	case TypeTailor:
+		return "tailor"
+	case TypeAdmin:
+		return "admin"
+	}
+	return "unknown"
+}
+
+// Principal is the authenticated account behind a request.
+type Principal struct {
+	ID         uint
+	Type       int
+	SessionID  uint
+	Generation int
+}
+
+// NewToken issues a short-lived access token for the session's current generation.
+func NewToken(session model.Session) (string, error) {
+	return sign(jwt.MapClaims{
+		"sub": session.PrincipalID,
+		"exp": time.Now().Add(AccessTokenLifetime).Unix(),
+		"typ": session.PrincipalType,
+		"sid": session.ID,
+		"gen": session.Generation,
+	})
+}
+
+func ParseToken(tokenString string) (Principal, error) {
+	claims, err := parseClaims(tokenString)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if _, ok := claims["pur"]; ok {
+		return Principal{}, errors.New("not an access token")
+	}
+
+	sub, ok := claims["sub"].(float64)
+	if !ok {
+		return Principal{}, errors.New("invalid token claims")
+	}
+
+	typ, ok := claims["typ"].(float64)
+	if !ok {
+		return Principal{}, errors.New("invalid token claims")
+	}
+
+	sid, ok := claims["sid"].(float64)
+	if !ok {
+		return Principal{}, errors.New("invalid token claims")
+	}
+
+	gen, ok := claims["gen"].(float64)
+	if !ok {
+		return Principal{}, errors.New("invalid token claims")
+	}
+
+	return Principal{ID: uint(sub), Type: int(typ), SessionID: uint(sid), Generation: int(gen)}, nil
+}
+
+// NewMFAToken issues the token handed out after a correct password when the
+// account still has to pass two-factor authentication. It can't be used as
+// an access token.
+func NewMFAToken(id uint, typ int) (string, error) {
+	return sign(jwt.MapClaims{
+		"sub": id,
+		"exp": time.Now().Add(MFATokenLifetime).Unix(),
+		"typ": typ,
+		"pur": "mfa",
+	})
+}
+
+func ParseMFAToken(tokenString string) (Principal, error) {
+	claims, err := parseClaims(tokenString)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if claims["pur"] != "mfa" {
+		return Principal{}, errors.New("not a two-factor token")
+	}
+
+	sub, ok := claims["sub"].(float64)
+	if !ok {
+		return Principal{}, errors.New("invalid token claims")
+	}
+
+	typ, ok := claims["typ"].(float64)
+	if !ok {
+		return Principal{}, errors.New("invalid token claims")
+	}
+
+	return Principal{ID: uint(sub), Type: int(typ)}, nil
+}
+
+func parseClaims(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, verificationKeyFor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
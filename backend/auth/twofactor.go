@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"errors"
+	model "main/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactor    = errors.New("invalid two-factor code")
+)
+
+func GetTwoFactor(tx *gorm.DB, id uint, typ int) (model.TwoFactor, bool) {
+	var twoFactor model.TwoFactor
+	found := tx.Where("principal_id = ? AND principal_type = ?", id, typ).Limit(1).Find(&twoFactor).RowsAffected != 0
+	return twoFactor, found
+}
+
+// TwoFactorEnabled reports whether the principal has confirmed TOTP enrolment.
+func TwoFactorEnabled(tx *gorm.DB, id uint, typ int) bool {
+	twoFactor, found := GetTwoFactor(tx, id, typ)
+	return found && twoFactor.IsEnabled()
+}
+
+// VerifyTOTP accepts a code from the principal's authenticator. Each code is
+// accepted only once; replaying it, even within its 30 seconds, fails.
+func VerifyTOTP(tx *gorm.DB, twoFactor model.TwoFactor, code string) error {
+	step, ok := matchTOTP(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactor
+	}
+
+	result := tx.Model(&model.TwoFactor{}).
+		Where("id = ? AND last_step < ?", twoFactor.ID, step).
+		Update("last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactor
+	}
+	return nil
+}
+
+// VerifySecondFactor accepts either a TOTP code or an unused recovery code.
+func VerifySecondFactor(tx *gorm.DB, id uint, typ int, code string) error {
+	twoFactor, found := GetTwoFactor(tx, id, typ)
+	if !found || !twoFactor.IsEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err := VerifyTOTP(tx, twoFactor, code); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrInvalidTwoFactor) {
+		return err
+	}
+
+	return useRecoveryCode(tx, id, typ, code)
+}
+
+// NewRecoveryCodes replaces the principal's recovery codes and returns the new
+// ones in plain text. Only their hashes are stored.
+func NewRecoveryCodes(tx *gorm.DB, id uint, typ int) ([]string, error) {
+	if err := tx.Unscoped().Where("principal_id = ? AND principal_type = ?", id, typ).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		secret, err := GenerateTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(secret[:5] + "-" + secret[5:10])
+
+		if err := tx.Create(&model.RecoveryCode{
+			PrincipalID:   id,
+			PrincipalType: typ,
+			CodeHash:      hashToken(code),
+		}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func useRecoveryCode(tx *gorm.DB, id uint, typ int, code string) error {
+	code = strings.ToLower(strings.TrimSpace(code))
+
+	result := tx.Model(&model.RecoveryCode{}).
+		Where("principal_id = ? AND principal_type = ? AND code_hash = ? AND used_at IS NULL", id, typ, hashToken(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactor
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"main/database"
+	model "main/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidUserToken = errors.New("token is invalid or has expired")
+
+// IssueUserToken creates a single-use token for the user and invalidates any
+// earlier unused token with the same purpose.
+func IssueUserToken(userID uint, email string, purpose string, lifetime time.Duration) (string, error) {
+	db := database.GetInstance()
+
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&model.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&model.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			Email:     email,
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(lifetime),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConsumeUserToken marks the token as used and returns it. The update is
+// conditional, so two concurrent requests cannot both redeem the same token.
+func ConsumeUserToken(tx *gorm.DB, token string, purpose string) (model.UserToken, error) {
+	var userToken model.UserToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).First(&userToken).Error; err != nil {
+		return model.UserToken{}, ErrInvalidUserToken
+	}
+
+	now := time.Now()
+	result := tx.Model(&model.UserToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", userToken.ID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return model.UserToken{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.UserToken{}, ErrInvalidUserToken
+	}
+
+	userToken.UsedAt = &now
+	return userToken, nil
+}
@@ -0,0 +1,244 @@
+// Package checkout prices what a buyer is about to pay for. Amounts are
+// computed from the catalogue on the server and handed back as a signed
+// quote, which order creation and payment accept instead of client totals.
+package checkout
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	model "main/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Quote kinds: ready-made products, or a custom request to one tailor.
+const (
+	KindOrder   = "order"
+	KindRequest = "request"
+)
+
+// TTL is how long a quote can be used for.
+const TTL = 15 * time.Minute
+
+var (
+	ErrProductUnavailable = errors.New("checkout: product not available")
+	ErrNoTailorPrice      = errors.New("checkout: tailor does not offer this outfit")
+	ErrNoPromo            = errors.New("checkout: promo code not available")
+	ErrInvalidQuote       = errors.New("checkout: invalid quote")
+	ErrQuoteExpired       = errors.New("checkout: quote expired")
+	ErrQuoteUsed          = errors.New("checkout: quote already placed")
+)
+
+// Line is one priced item. Orders have a line per product; requests have a
+// single line for the outfit.
+type Line struct {
+	TailorID  uint
+	ProductID uint `json:",omitempty"`
+	OutfitID  uint `json:",omitempty"`
+	Name      string
+	Price     int
+}
+
+// Quote is the server's price for a checkout. Total is what the buyer pays:
+// the lines, less the promo discount, plus shipping, and never below zero.
+type Quote struct {
+	ID          string
+	Kind        string
+	UserID      uint
+	Lines       []Line
+	Subtotal    int
+	Discount    int
+	ShippingFee int
+	Total       int
+	PromoCode   string `json:",omitempty"`
+	ExpiresAt   time.Time
+}
+
+// Expired reports whether the quote can no longer be used to place an order.
+func (q Quote) Expired(now time.Time) bool {
+	return now.After(q.ExpiresAt)
+}
+
+// TailorIDs lists the tailors in the quote, in the order they first appear.
+func (q Quote) TailorIDs() []uint {
+	var ids []uint
+	seen := map[uint]bool{}
+	for _, line := range q.Lines {
+		if !seen[line.TailorID] {
+			seen[line.TailorID] = true
+			ids = append(ids, line.TailorID)
+		}
+	}
+	return ids
+}
+
+// ProductIDs lists the products in an order quote.
+func (q Quote) ProductIDs() []uint {
+	var ids []uint
+	for _, line := range q.Lines {
+		if line.ProductID != 0 {
+			ids = append(ids, line.ProductID)
+		}
+	}
+	return ids
+}
+
+// QuoteOrder prices the buyer's products. Every product must exist and
+// still be for sale.
+func QuoteOrder(tx *gorm.DB, userID uint, productIDs []uint, promoCode string, shippingFee int) (Quote, error) {
+	unique := map[uint]bool{}
+	for _, id := range productIDs {
+		unique[id] = true
+	}
+
+	var products []model.Product
+	if err := tx.Where("id IN ? AND is_active = ?", productIDs, true).Order("id").Find(&products).Error; err != nil {
+		return Quote{}, err
+	}
+	if len(products) == 0 || len(products) != len(unique) {
+		return Quote{}, ErrProductUnavailable
+	}
+
+	lines := make([]Line, 0, len(products))
+	for _, product := range products {
+		lines = append(lines, Line{
+			TailorID:  product.TailorID,
+			ProductID: product.ID,
+			Name:      product.Name,
+			Price:     product.Price,
+		})
+	}
+
+	return price(tx, KindOrder, userID, lines, promoCode, shippingFee)
+}
+
+// QuoteRequest prices a custom request at the tailor's price for the outfit.
+func QuoteRequest(tx *gorm.DB, userID, tailorID, outfitID uint, promoCode string, shippingFee int) (Quote, error) {
+	var tailorPrice model.TailorPrice
+	if tx.Where("tailor_id = ? AND outfit_id = ?", tailorID, outfitID).Limit(1).Find(&tailorPrice).RowsAffected == 0 {
+		return Quote{}, ErrNoTailorPrice
+	}
+
+	var outfit model.Outfit
+	if err := tx.First(&outfit, outfitID).Error; err != nil {
+		return Quote{}, ErrNoTailorPrice
+	}
+
+	lines := []Line{{
+		TailorID: tailorID,
+		OutfitID: outfitID,
+		Name:     outfit.Category,
+		Price:    tailorPrice.Price,
+	}}
+
+	return price(tx, KindRequest, userID, lines, promoCode, shippingFee)
+}
+
+func price(tx *gorm.DB, kind string, userID uint, lines []Line, promoCode string, shippingFee int) (Quote, error) {
+	quote := Quote{
+		Kind:        kind,
+		UserID:      userID,
+		Lines:       lines,
+		ShippingFee: shippingFee,
+		PromoCode:   promoCode,
+		ExpiresAt:   time.Now().Add(TTL).Truncate(time.Second),
+	}
+	for _, line := range lines {
+		quote.Subtotal += line.Price
+	}
+
+	if promoCode != "" {
+		discount, err := promoDiscount(tx, userID, promoCode)
+		if err != nil {
+			return Quote{}, err
+		}
+		quote.Discount = min(discount, quote.Subtotal+shippingFee)
+	}
+	quote.Total = quote.Subtotal - quote.Discount + shippingFee
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Quote{}, err
+	}
+	quote.ID = hex.EncodeToString(id)
+
+	return quote, nil
+}
+
+// promoDiscount is the discount of a promo the buyer holds at least one of.
+func promoDiscount(tx *gorm.DB, userID uint, promoCode string) (int, error) {
+	var userPromo model.UserPromo
+	if tx.Where("promo_code = ? AND user_id = ? AND quantity > 0", promoCode, userID).Limit(1).Find(&userPromo).RowsAffected == 0 {
+		return 0, ErrNoPromo
+	}
+
+	var promo model.Promo
+	if tx.Where("promo_code = ?", promoCode).Limit(1).Find(&promo).RowsAffected == 0 {
+		return 0, ErrNoPromo
+	}
+	return promo.Discount, nil
+}
+
+// Sign encodes the quote as a token: its JSON and an HMAC-SHA256 over it,
+// both base64url encoded and joined by a dot.
+func Sign(secret string, quote Quote) (string, error) {
+	payload, err := json.Marshal(quote)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + mac(secret, encoded), nil
+}
+
+// Use marks the quote placed, or returns ErrQuoteUsed if it already was. It
+// must run in the transaction that places the quote; a concurrent attempt
+// waits for that transaction and then fails.
+func Use(tx *gorm.DB, quote Quote) error {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.UsedQuote{
+		QuoteID: quote.ID,
+		Kind:    quote.Kind,
+		UserID:  quote.UserID,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrQuoteUsed
+	}
+	return nil
+}
+
+// Verify checks the token's signature and returns its quote. Whether the
+// quote has expired is up to the caller: it limits placing an order, not
+// paying for one that was placed in time.
+func Verify(secret, token string) (Quote, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(mac(secret, encoded))) {
+		return Quote{}, ErrInvalidQuote
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Quote{}, ErrInvalidQuote
+	}
+
+	var quote Quote
+	if err := json.Unmarshal(payload, &quote); err != nil {
+		return Quote{}, ErrInvalidQuote
+	}
+	return quote, nil
+}
+
+func mac(secret, encoded string) string {
+	hash := hmac.New(sha256.New, []byte(secret))
+	hash.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(hash.Sum(nil))
+}
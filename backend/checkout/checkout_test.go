@@ -0,0 +1,43 @@
+package checkout
+
+import (
+	"errors"
+	model "main/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestUsePlacesAQuoteOnce(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "checkout.db")
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.UsedQuote{}); err != nil {
+		t.Fatal(err)
+	}
+
+	quote := Quote{ID: "q1", Kind: KindRequest, UserID: 7}
+	if err := Use(db, quote); err != nil {
+		t.Fatalf("first Use() error = %v", err)
+	}
+	if err := Use(db, quote); !errors.Is(err, ErrQuoteUsed) {
+		t.Fatalf("second Use() error = %v, want ErrQuoteUsed", err)
+	}
+	if err := Use(db, Quote{ID: "q2", Kind: KindOrder, UserID: 7}); err != nil {
+		t.Fatalf("Use() of another quote error = %v", err)
+	}
+
+	// A placement that rolls back leaves the quote unused.
+	db.Transaction(func(tx *gorm.DB) error {
+		Use(tx, Quote{ID: "q3"})
+		return errors.New("rolled back")
+	})
+	if err := Use(db, Quote{ID: "q3"}); err != nil {
+		t.Fatalf("Use() after a rolled back placement error = %v", err)
+	}
+}
@@ -0,0 +1,470 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings that differ between developer machines, staging
+// and production. Values are read from an optional YAML file and then
+// overridden by TAILORTECH_* environment variables.
+type Config struct {
+	Port        string   `yaml:"port"`
+	DatabaseDSN string   `yaml:"database_dsn"`
+	JWTSecret   string   `yaml:"jwt_secret"`
+	CORSOrigins []string `yaml:"cors_origins"`
+
+	// JWTSigningKey is a PEM private key (RSA or Ed25519) used to sign new
+	// tokens. JWTVerificationKeys lists older keys that are still accepted.
+	JWTSigningKey       string   `yaml:"jwt_signing_key"`
+	JWTVerificationKeys []string `yaml:"jwt_verification_keys"`
+
+	Cookie CookieConfig `yaml:"cookie"`
+
+	// Withdrawals above this amount always need a fresh TOTP code.
+	WithdrawalTOTPThreshold int `yaml:"withdrawal_totp_threshold"`
+
+	// Bounds on a single withdrawal request, and on the total a tailor may
+	// request in 24 hours.
+	WithdrawalMinimum    int `yaml:"withdrawal_minimum"`
+	WithdrawalMaximum    int `yaml:"withdrawal_maximum"`
+	WithdrawalDailyLimit int `yaml:"withdrawal_daily_limit"`
+
+	// PublicURL is the address of the app, used to build links in emails.
+	PublicURL string     `yaml:"public_url"`
+	Mail      MailConfig `yaml:"mail"`
+
+	PaymentGateway PaymentGatewayConfig `yaml:"payment_gateway"`
+	QRIS           QRISConfig           `yaml:"qris"`
+	Checkout       CheckoutConfig       `yaml:"checkout"`
+	Orders         OrdersConfig         `yaml:"orders"`
+}
+
+// CookieConfig controls the auth cookies. An empty Domain scopes them to the
+// host that served the response, which also works when the app reaches the
+// server by LAN IP.
+type CookieConfig struct {
+	Domain   string `yaml:"domain"`
+	Secure   bool   `yaml:"secure"`
+	HTTPOnly bool   `yaml:"http_only"`
+	SameSite string `yaml:"same_site"`
+}
+
+func (c CookieConfig) SameSiteMode() http.SameSite {
+	switch strings.ToLower(c.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+// MailConfig selects how outgoing email is delivered. The outbox driver
+// writes each message to a file, which is handy on developer machines.
+type MailConfig struct {
+	Driver       string `yaml:"driver"`
+	From         string `yaml:"from"`
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     string `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	OutboxDir    string `yaml:"outbox_dir"`
+}
+
+// PaymentGatewayConfig selects the provider that collects wallet top-ups.
+// WebhookSecret is shared with the provider and signs its callbacks. The
+// mock provider runs inside this server, for development. MockPayEndpoint
+// exposes POST /gateway/mock/:reference/pay, which marks any mock payment
+// paid without authentication, so it must stay off outside development.
+type PaymentGatewayConfig struct {
+	Provider        string `yaml:"provider"`
+	WebhookSecret   string `yaml:"webhook_secret"`
+	MockPayEndpoint bool   `yaml:"mock_pay_endpoint"`
+}
+
+// QRISConfig is the merchant shown when a customer scans one of our QRIS
+// codes. MerchantID is the NMID issued on QRIS registration.
+type QRISConfig struct {
+	MerchantID   string `yaml:"merchant_id"`
+	MerchantName string `yaml:"merchant_name"`
+	MerchantCity string `yaml:"merchant_city"`
+	PostalCode   string `yaml:"postal_code"`
+	CategoryCode string `yaml:"category_code"`
+}
+
+// CheckoutConfig prices checkouts. QuoteSecret signs the quotes the server
+// hands out, so order creation and payment can trust the amounts in them.
+// ShippingFee is charged once per checkout.
+type CheckoutConfig struct {
+	QuoteSecret string `yaml:"quote_secret"`
+	ShippingFee int    `yaml:"shipping_fee"`
+}
+
+// OrdersConfig controls the background jobs that move orders and requests
+// along on their own. Transactions still unpaid UnpaidTimeoutMinutes after
+// they were placed are cancelled and their products put back on sale.
+// Transactions delivered AutoCompleteDays ago are finished and the tailor
+// paid; buyer and tailor are warned AutoCompleteNoticeDays beforehand.
+type OrdersConfig struct {
+	UnpaidTimeoutMinutes   int `yaml:"unpaid_timeout_minutes"`
+	AutoCompleteDays       int `yaml:"auto_complete_days"`
+	AutoCompleteNoticeDays int `yaml:"auto_complete_notice_days"`
+}
+
+const defaultConfigFile = "config.yaml"
+
+var cfg *Config
+
+// Get returns the configuration loaded at startup.
+func Get() *Config {
+	if cfg == nil {
+		panic("config: Get called before Load")
+	}
+	return cfg
+}
+
+// Load reads the YAML file named by TAILORTECH_CONFIG (or config.yaml when it
+// exists), applies environment overrides and validates the result.
+func Load() (*Config, error) {
+	loaded := &Config{
+		Port:                    "8000",
+		CORSOrigins:             []string{"http://localhost:8081"},
+		PublicURL:               "http://localhost:8081",
+		WithdrawalTOTPThreshold: 1000,
+		WithdrawalMinimum:       50,
+		WithdrawalMaximum:       10000,
+		WithdrawalDailyLimit:    20000,
+		Cookie: CookieConfig{
+			HTTPOnly: true,
+			SameSite: "lax",
+		},
+		Mail: MailConfig{
+			Driver:    "outbox",
+			From:      "TailorTech <no-reply@tailortech.local>",
+			SMTPPort:  "587",
+			OutboxDir: "outbox",
+		},
+		PaymentGateway: PaymentGatewayConfig{
+			Provider: "mock",
+		},
+		QRIS: QRISConfig{
+			MerchantID:   "ID1000000000001",
+			MerchantName: "TailorTech",
+			MerchantCity: "JAKARTA",
+			PostalCode:   "10110",
+			CategoryCode: "5699",
+		},
+		Checkout: CheckoutConfig{
+			ShippingFee: 10,
+		},
+		Orders: OrdersConfig{
+			UnpaidTimeoutMinutes:   60,
+			AutoCompleteDays:       7,
+			AutoCompleteNoticeDays: 2,
+		},
+	}
+
+	path, explicit := os.LookupEnv("TAILORTECH_CONFIG")
+	if !explicit {
+		path = defaultConfigFile
+	}
+
+	if err := loadFile(loaded, path, explicit); err != nil {
+		return nil, err
+	}
+
+	if err := loadEnv(loaded); err != nil {
+		return nil, err
+	}
+
+	if err := loaded.validate(); err != nil {
+		return nil, err
+	}
+
+	cfg = loaded
+	return cfg, nil
+}
+
+func loadFile(c *Config, path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return nil
+		}
+		return fmt.Errorf("reading config file %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadEnv(c *Config) error {
+	if v, ok := os.LookupEnv("TAILORTECH_PORT"); ok {
+		c.Port = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_DATABASE_DSN"); ok {
+		c.DatabaseDSN = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_JWT_SECRET"); ok {
+		c.JWTSecret = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_CORS_ORIGINS"); ok {
+		c.CORSOrigins = splitList(v)
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_JWT_SIGNING_KEY"); ok {
+		c.JWTSigningKey = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_JWT_VERIFICATION_KEYS"); ok {
+		c.JWTVerificationKeys = splitList(v)
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_COOKIE_DOMAIN"); ok {
+		c.Cookie.Domain = v
+	}
+	if err := lookupBool("TAILORTECH_COOKIE_SECURE", &c.Cookie.Secure); err != nil {
+		return err
+	}
+	if err := lookupBool("TAILORTECH_COOKIE_HTTP_ONLY", &c.Cookie.HTTPOnly); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_COOKIE_SAME_SITE"); ok {
+		c.Cookie.SameSite = v
+	}
+	if err := lookupInt("TAILORTECH_WITHDRAWAL_TOTP_THRESHOLD", &c.WithdrawalTOTPThreshold); err != nil {
+		return err
+	}
+	if err := lookupInt("TAILORTECH_WITHDRAWAL_MINIMUM", &c.WithdrawalMinimum); err != nil {
+		return err
+	}
+	if err := lookupInt("TAILORTECH_WITHDRAWAL_MAXIMUM", &c.WithdrawalMaximum); err != nil {
+		return err
+	}
+	if err := lookupInt("TAILORTECH_WITHDRAWAL_DAILY_LIMIT", &c.WithdrawalDailyLimit); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_PUBLIC_URL"); ok {
+		c.PublicURL = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_MAIL_DRIVER"); ok {
+		c.Mail.Driver = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_MAIL_FROM"); ok {
+		c.Mail.From = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_SMTP_HOST"); ok {
+		c.Mail.SMTPHost = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_SMTP_PORT"); ok {
+		c.Mail.SMTPPort = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_SMTP_USERNAME"); ok {
+		c.Mail.SMTPUsername = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_SMTP_PASSWORD"); ok {
+		c.Mail.SMTPPassword = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_MAIL_OUTBOX_DIR"); ok {
+		c.Mail.OutboxDir = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_PAYMENT_PROVIDER"); ok {
+		c.PaymentGateway.Provider = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_PAYMENT_WEBHOOK_SECRET"); ok {
+		c.PaymentGateway.WebhookSecret = v
+	}
+	if err := lookupBool("TAILORTECH_PAYMENT_MOCK_PAY_ENDPOINT", &c.PaymentGateway.MockPayEndpoint); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_QRIS_MERCHANT_ID"); ok {
+		c.QRIS.MerchantID = v
+	}
+	if v, ok := os.LookupEnv("TAILORTECH_QUOTE_SECRET"); ok {
+		c.Checkout.QuoteSecret = v
+	}
+	if err := lookupInt("TAILORTECH_SHIPPING_FEE", &c.Checkout.ShippingFee); err != nil {
+		return err
+	}
+	if err := lookupInt("TAILORTECH_UNPAID_TIMEOUT_MINUTES", &c.Orders.UnpaidTimeoutMinutes); err != nil {
+		return err
+	}
+	if err := lookupInt("TAILORTECH_AUTO_COMPLETE_DAYS", &c.Orders.AutoCompleteDays); err != nil {
+		return err
+	}
+	if err := lookupInt("TAILORTECH_AUTO_COMPLETE_NOTICE_DAYS", &c.Orders.AutoCompleteNoticeDays); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	var problems []string
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("port %q must be a number between 1 and 65535", c.Port))
+	}
+
+	if c.DatabaseDSN == "" {
+		problems = append(problems, "database_dsn (TAILORTECH_DATABASE_DSN) is required")
+	}
+
+	if c.JWTSecret == "" && c.JWTSigningKey == "" {
+		problems = append(problems, "either jwt_signing_key (TAILORTECH_JWT_SIGNING_KEY) or jwt_secret (TAILORTECH_JWT_SECRET) is required")
+	} else if c.JWTSecret != "" && len(c.JWTSecret) < 32 {
+		problems = append(problems, "jwt_secret must be at least 32 characters long")
+	}
+
+	if len(c.CORSOrigins) == 0 {
+		problems = append(problems, "cors_origins (TAILORTECH_CORS_ORIGINS) must list at least one origin")
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("cors origin %q must be a full URL such as http://localhost:8081", origin))
+		}
+	}
+
+	switch strings.ToLower(c.Cookie.SameSite) {
+	case "lax", "strict":
+	case "none":
+		if !c.Cookie.Secure {
+			problems = append(problems, "cookie.same_site none requires cookie.secure to be true")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("cookie.same_site %q must be lax, strict or none", c.Cookie.SameSite))
+	}
+
+	if c.WithdrawalTOTPThreshold < 0 {
+		problems = append(problems, "withdrawal_totp_threshold must not be negative")
+	}
+
+	if c.WithdrawalMinimum < 1 {
+		problems = append(problems, "withdrawal_minimum must be at least 1")
+	}
+	if c.WithdrawalMaximum < c.WithdrawalMinimum {
+		problems = append(problems, "withdrawal_maximum must not be below withdrawal_minimum")
+	}
+	if c.WithdrawalDailyLimit < c.WithdrawalMaximum {
+		problems = append(problems, "withdrawal_daily_limit must not be below withdrawal_maximum")
+	}
+	if c.WithdrawalTOTPThreshold > c.WithdrawalMaximum {
+		problems = append(problems, "withdrawal_totp_threshold must not be above withdrawal_maximum, or no withdrawal would need a code")
+	}
+
+	if u, err := url.Parse(c.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("public_url %q must be a full URL", c.PublicURL))
+	}
+
+	if c.Mail.From == "" {
+		problems = append(problems, "mail.from (TAILORTECH_MAIL_FROM) is required")
+	}
+
+	switch c.Mail.Driver {
+	case "smtp":
+		if c.Mail.SMTPHost == "" {
+			problems = append(problems, "mail.smtp_host (TAILORTECH_SMTP_HOST) is required for the smtp driver")
+		}
+		if c.Mail.SMTPPort == "" {
+			problems = append(problems, "mail.smtp_port (TAILORTECH_SMTP_PORT) is required for the smtp driver")
+		}
+	case "outbox":
+		if c.Mail.OutboxDir == "" {
+			problems = append(problems, "mail.outbox_dir (TAILORTECH_MAIL_OUTBOX_DIR) is required for the outbox driver")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("mail.driver %q must be either smtp or outbox", c.Mail.Driver))
+	}
+
+	if c.PaymentGateway.Provider == "" {
+		problems = append(problems, "payment_gateway.provider (TAILORTECH_PAYMENT_PROVIDER) is required")
+	}
+	if c.PaymentGateway.MockPayEndpoint && c.PaymentGateway.Provider != "mock" {
+		problems = append(problems, "payment_gateway.mock_pay_endpoint only works with the mock provider")
+	}
+	if len(c.PaymentGateway.WebhookSecret) < 32 {
+		problems = append(problems, "payment_gateway.webhook_secret (TAILORTECH_PAYMENT_WEBHOOK_SECRET) must be at least 32 characters long")
+	}
+
+	if c.QRIS.MerchantID == "" {
+		problems = append(problems, "qris.merchant_id (TAILORTECH_QRIS_MERCHANT_ID) is required")
+	}
+	if c.QRIS.MerchantName == "" || len(c.QRIS.MerchantName) > 25 {
+		problems = append(problems, "qris.merchant_name must be between 1 and 25 characters")
+	}
+	if c.QRIS.MerchantCity == "" || len(c.QRIS.MerchantCity) > 15 {
+		problems = append(problems, "qris.merchant_city must be between 1 and 15 characters")
+	}
+	if len(c.QRIS.CategoryCode) != 4 {
+		problems = append(problems, "qris.category_code must be a four-digit merchant category code")
+	}
+
+	if len(c.Checkout.QuoteSecret) < 32 {
+		problems = append(problems, "checkout.quote_secret (TAILORTECH_QUOTE_SECRET) must be at least 32 characters long")
+	}
+	if c.Checkout.ShippingFee < 0 {
+		problems = append(problems, "checkout.shipping_fee must not be negative")
+	}
+
+	if c.Orders.UnpaidTimeoutMinutes < 1 {
+		problems = append(problems, "orders.unpaid_timeout_minutes must be at least 1")
+	}
+	if c.Orders.AutoCompleteDays < 2 {
+		problems = append(problems, "orders.auto_complete_days must be at least 2")
+	}
+	if c.Orders.AutoCompleteNoticeDays < 1 || c.Orders.AutoCompleteNoticeDays >= c.Orders.AutoCompleteDays {
+		problems = append(problems, "orders.auto_complete_notice_days must be at least 1 and below orders.auto_complete_days")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func lookupInt(name string, target *int) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s must be a number, got %q", name, v)
+	}
+	*target = parsed
+	return nil
+}
+
+func lookupBool(name string, target *bool) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s must be true or false, got %q", name, v)
+	}
+	*target = parsed
+	return nil
+}
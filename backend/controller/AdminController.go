@@ -0,0 +1,358 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"main/auth"
+	"main/database"
+	"main/dto"
+	"main/ledger"
+	model "main/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateAdmin adds an administrator account. Admins can't sign up through
+// the API; they are created from the command line.
+func CreateAdmin(name string, email string, password string) error {
+	db := database.GetInstance()
+
+	if !model.IsValidEmail(email) {
+		return errors.New("please provide a valid email address")
+	}
+
+	if passwordChecks := validatePassword(password); len(passwordChecks) > 0 {
+		return errors.New(strings.Join(passwordChecks, ", "))
+	}
+
+	var query model.Admin
+	if db.Where("email = ?", email).Find(&query).RowsAffected != 0 {
+		return errors.New("this email has been registered")
+	}
+
+	admin := model.Admin{Name: name, Email: email}
+	if err := admin.SetPassword(password); err != nil {
+		return err
+	}
+
+	return db.Create(&admin).Error
+}
+
+func AdminGetUsers(c *gin.Context) {
+	db := database.GetInstance()
+
+	query := db.Model(&model.User{}).Omit("password")
+
+	if search := c.Query("query"); search != "" {
+		search = "%" + strings.ToLower(search) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", search, search)
+	}
+
+	switch c.Query("suspended") {
+	case "true":
+		query = query.Where("suspended_at IS NOT NULL")
+	case "false":
+		query = query.Where("suspended_at IS NULL")
+	}
+
+	var users []model.User
+	if err := paginate(c, query).Order("id").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": dto.NewAdminUsers(users)})
+}
+
+func AdminGetTailors(c *gin.Context) {
+	db := database.GetInstance()
+
+	query := db.Model(&model.Tailor{}).Omit("password")
+
+	if search := c.Query("query"); search != "" {
+		search = "%" + strings.ToLower(search) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", search, search)
+	}
+
+	switch c.Query("suspended") {
+	case "true":
+		query = query.Where("suspended_at IS NOT NULL")
+	case "false":
+		query = query.Where("suspended_at IS NULL")
+	}
+
+	var tailors []model.Tailor
+	if err := paginate(c, query).Order("id").Find(&tailors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tailors"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tailors": dto.NewAdminTailors(tailors)})
+}
+
+type SuspendInput struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+func SuspendUser(c *gin.Context) {
+	setSuspended(c, auth.TypeUser, &model.User{}, true)
+}
+
+func UnsuspendUser(c *gin.Context) {
+	setSuspended(c, auth.TypeUser, &model.User{}, false)
+}
+
+func SuspendTailor(c *gin.Context) {
+	setSuspended(c, auth.TypeTailor, &model.Tailor{}, true)
+}
+
+func UnsuspendTailor(c *gin.Context) {
+	setSuspended(c, auth.TypeTailor, &model.Tailor{}, false)
+}
+
+// setSuspended suspends or reinstates a user or tailor. Suspending also ends
+// all of the account's sessions.
+func setSuspended(c *gin.Context, typ int, account interface{}, suspend bool) {
+	db := database.GetInstance()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	updates := map[string]interface{}{"suspended_at": nil, "suspended_reason": ""}
+	if suspend {
+		var input SuspendInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates = map[string]interface{}{"suspended_at": time.Now(), "suspended_reason": input.Reason}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(account).Where("id = ?", id).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if suspend {
+			return auth.RevokeAllSessions(tx, uint(id), typ)
+		}
+		return nil
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update suspension: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update account"})
+		return
+	}
+
+	if suspend {
+		c.JSON(http.StatusOK, gin.H{"message": "Account suspended"})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"message": "Account reinstated"})
+	}
+}
+
+func AdminDeactivateProduct(c *gin.Context) {
+	db := database.GetInstance()
+
+	result := db.Model(&model.Product{}).Where("id = ?", c.Param("id")).Update("is_active", false)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate product"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product deactivated"})
+}
+
+type AdjustBalanceInput struct {
+	Amount int    `json:"amount" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+func AdjustUserBalance(c *gin.Context) {
+	adjustBalance(c, auth.TypeUser, "users")
+}
+
+func AdjustTailorBalance(c *gin.Context) {
+	adjustBalance(c, auth.TypeTailor, "tailors")
+}
+
+func adjustBalance(c *gin.Context, typ int, table string) {
+	db := database.GetInstance()
+	admin, _ := auth.GetPrincipal(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account ID"})
+		return
+	}
+
+	var input AdjustBalanceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var adjustment model.BalanceAdjustment
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Table(table).Where("id = ? AND deleted_at IS NULL", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		wallet := ledger.UserWallet(uint(id))
+		if typ == auth.TypeTailor {
+			wallet = ledger.TailorWallet(uint(id))
+		}
+
+		entry, err := ledger.Transfer(tx, model.EntryAdjustment, input.Reason, ledger.Adjustments, wallet, input.Amount)
+		if err != nil {
+			return err
+		}
+		balance := entry.Postings[1].BalanceAfter
+
+		adjustment = model.BalanceAdjustment{
+			AdminID:      admin.ID,
+			AccountType:  auth.TypeName(typ),
+			AccountID:    uint(id),
+			Amount:       input.Amount,
+			BalanceAfter: balance,
+			Reason:       input.Reason,
+		}
+		return tx.Create(&adjustment).Error
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+		return
+	}
+	if errors.Is(err, ledger.ErrInsufficientFunds) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Adjustment would make the balance negative"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to adjust balance: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Balance adjusted", "adjustment": dto.NewBalanceAdjustment(adjustment)})
+}
+
+func AdminGetTransactions(c *gin.Context) {
+	db := database.GetInstance()
+
+	query := db.Model(&model.Transaction{}).Preload("Products").Preload("Requests")
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID := c.Query("userId"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if tailorID := c.Query("tailorId"); tailorID != "" {
+		query = query.Where("tailor_id = ?", tailorID)
+	}
+
+	var transactions []model.Transaction
+	if err := paginate(c, query).Order("transaction_date desc").Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transactions": dto.NewTransactions(transactions)})
+}
+
+// paginate applies the page and pageSize query parameters, 50 rows a page by default.
+func paginate(c *gin.Context, query *gorm.DB) *gorm.DB {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "50"))
+	if err != nil || pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+
+	return query.Offset((page - 1) * pageSize).Limit(pageSize)
+}
+
+func GetLoginLocks(c *gin.Context) {
+	db := database.GetInstance()
+
+	var throttles []model.LoginThrottle
+	if err := db.Where("locked_until > ?", time.Now()).Order("locked_until desc").Find(&throttles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login locks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"locks": dto.NewLoginLocks(throttles)})
+}
+
+func UnlockLogin(c *gin.Context) {
+	type UnlockInput struct {
+		AccountType string `json:"accountType"`
+		Email       string `json:"email"`
+		IP          string `json:"ip"`
+	}
+
+	var input UnlockInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var subject string
+	switch {
+	case input.IP != "":
+		subject = auth.IPSubject(input.IP)
+	case input.Email != "":
+		typ, ok := accountTypes[input.AccountType]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown account type %q", input.AccountType)})
+			return
+		}
+		subject = auth.AccountSubject(typ, input.Email)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide an ip, or an email with its accountType"})
+		return
+	}
+
+	if err := auth.UnlockLogin(subject); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login unlocked"})
+}
+
+var accountTypes = map[string]int{
+	auth.TypeName(auth.TypeUser):   auth.TypeUser,
+	auth.TypeName(auth.TypeTailor): auth.TypeTailor,
+	auth.TypeName(auth.TypeAdmin):  auth.TypeAdmin,
+}
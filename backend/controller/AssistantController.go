@@ -3,6 +3,7 @@ package controller
 import (
 	"fmt"
 	"main/database"
+	"main/dto"
 	models "main/models"
 	"net/http"
 	"time"
@@ -51,7 +52,7 @@ func GetAvailableAssistants(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, availableAssistants)
+	c.JSON(http.StatusOK, dto.NewAssistants(availableAssistants))
 }
 
 func BookAssistant(c *gin.Context) {
@@ -63,6 +64,10 @@ func BookAssistant(c *gin.Context) {
 		return
 	}
 
+	if !authorizeUser(c, request.UserID) {
+		return
+	}
+
 	date, err := time.Parse("2006-01-02", request.Date)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
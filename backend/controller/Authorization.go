@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"main/auth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func forbidden(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to access this resource"})
+}
+
+func authorizeUser(c *gin.Context, userID uint) bool {
+	if !auth.Owns(c, auth.TypeUser, userID) {
+		forbidden(c)
+		return false
+	}
+	return true
+}
+
+func authorizeUserParam(c *gin.Context, userID string) bool {
+	if !auth.OwnsParam(c, auth.TypeUser, userID) {
+		forbidden(c)
+		return false
+	}
+	return true
+}
+
+func authorizeTailor(c *gin.Context, tailorID uint) bool {
+	if !auth.Owns(c, auth.TypeTailor, tailorID) {
+		forbidden(c)
+		return false
+	}
+	return true
+}
+
+func authorizeTailorParam(c *gin.Context, tailorID string) bool {
+	if !auth.OwnsParam(c, auth.TypeTailor, tailorID) {
+		forbidden(c)
+		return false
+	}
+	return true
+}
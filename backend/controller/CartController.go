@@ -2,6 +2,7 @@ package controller
 
 import (
 	"main/database"
+	"main/dto"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +23,10 @@ func AddToCart(c *gin.Context) {
 		return
 	}
 
+	if !authorizeUser(c, uint(input.UserID)) {
+		return
+	}
+
 	db.Create(&input)
 	c.JSON(http.StatusOK, gin.H{"message": "Product added to cart"})
 }
@@ -30,26 +35,16 @@ func GetCart(c *gin.Context) {
 	db := database.GetInstance()
 	userID := c.Param("id")
 
-	type CartProduct struct {
-		ID         int    `json:"ID"`
-		Name       string `json:"Product"`
-		Price      int    `json:"Price"`
-		Size       string `json:"Size"`
-		ImgUrl     string `json:"ImgUrl"`
-		TailorName string `json:"Tailor"`
-	}
-
-	type Cart struct {
-		TotalPrice int          `json:"TotalPrice"`
-		Products   []CartProduct `json:"Products"`
+	if !authorizeUserParam(c, userID) {
+		return
 	}
 
-	cartProducts := Cart{
+	cartProducts := dto.Cart{
 		TotalPrice: 0,
 	}
 
 	if err := db.Table("products").
-		Select("products.id as id, products.name as name, products.price, products.size, products.img_url as img_url, tailors.name as tailor_name").
+		Select("products.id as id, products.name as product, products.desc, products.price, products.size, products.img_url as img_url, tailors.name as tailor").
 		Joins("JOIN carts ON carts.product_id = products.id").
 		Joins("JOIN tailors ON tailors.id = products.tailor_id").
 		Where("carts.user_id = ?", userID).
@@ -85,6 +80,10 @@ func RemoveFromCart(c *gin.Context) {
 		return
 	}
 
+	if !authorizeUser(c, uint(input.UserID)) {
+		return
+	}
+
 	if err := db.Where("user_id = ? AND product_id = ?", input.UserID, input.ProductID).Delete(&Cart{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove item from cart"})
 		return
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"main/checkout"
+	"main/config"
+	"main/database"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuoteInput asks for the price of either ProductIDs, or a custom request
+// of RequestType (an outfit category) to TailorID.
+type QuoteInput struct {
+	UserID      uint   `json:"userId"`
+	ProductIDs  []uint `json:"productIds"`
+	TailorID    uint   `json:"tailorId"`
+	RequestType string `json:"requestType"`
+	PromoCode   string `json:"promoCode"`
+}
+
+// GetCheckoutQuote prices a checkout and returns the quote with a signed
+// token. Orders, requests and their payment are only accepted with a token,
+// so the buyer pays exactly what the server computed.
+func GetCheckoutQuote(c *gin.Context) {
+	db := database.GetInstance()
+	cfg := config.Get().Checkout
+
+	var input QuoteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !authorizeUser(c, input.UserID) {
+		return
+	}
+
+	var quote checkout.Quote
+	var err error
+	switch {
+	case len(input.ProductIDs) > 0:
+		quote, err = checkout.QuoteOrder(db, input.UserID, input.ProductIDs, input.PromoCode, cfg.ShippingFee)
+	case input.TailorID != 0 && input.RequestType != "":
+		var outfitID uint
+		db.Raw("select id from outfits where upper(category) like ?", input.RequestType).Scan(&outfitID)
+		quote, err = checkout.QuoteRequest(db, input.UserID, input.TailorID, outfitID, input.PromoCode, cfg.ShippingFee)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either productIds, or tailorId and requestType, are required"})
+		return
+	}
+	if respondCheckoutError(c, err) {
+		return
+	}
+
+	token, err := checkout.Sign(cfg.QuoteSecret, quote)
+	if respondCheckoutError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quote": quote, "token": token})
+}
+
+// verifyQuote checks that the token is a quote of the given kind for the
+// buyer, and that it hasn't expired when one is about to be placed.
+func verifyQuote(c *gin.Context, token string, userID uint, kind string) (checkout.Quote, bool) {
+	quote, err := checkout.Verify(config.Get().Checkout.QuoteSecret, token)
+	switch {
+	case err != nil:
+	case quote.UserID != userID || (kind != "" && quote.Kind != kind):
+		err = checkout.ErrInvalidQuote
+	case kind != "" && quote.Expired(time.Now()):
+		err = checkout.ErrQuoteExpired
+	}
+	if respondCheckoutError(c, err) {
+		return checkout.Quote{}, false
+	}
+	return quote, true
+}
+
+// respondCheckoutError answers with the error pricing or placing a checkout
+// failed with, and reports whether there was one.
+func respondCheckoutError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, checkout.ErrProductUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Some products are no longer available"})
+	case errors.Is(err, checkout.ErrNoTailorPrice):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This tailor does not offer that outfit"})
+	case errors.Is(err, checkout.ErrNoPromo):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon is not available"})
+	case errors.Is(err, checkout.ErrInvalidQuote):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid checkout quote"})
+	case errors.Is(err, checkout.ErrQuoteExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": "The checkout quote has expired, please review the price again"})
+	case errors.Is(err, checkout.ErrQuoteUsed):
+		c.JSON(http.StatusConflict, gin.H{"error": "This checkout quote has already been used"})
+	default:
+		log.Printf("Failed to process checkout: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process checkout"})
+	}
+	return true
+}
@@ -24,6 +24,10 @@ func ExchangePointsForCoupon(c *gin.Context) {
 		return
 	}
 
+	if !authorizeUser(c, input.UserID) {
+		return
+	}
+
 	var user models.User
 	if db.Where("id = ?", input.UserID).First(&user).RowsAffected == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -68,7 +72,7 @@ func ExchangePointsForCoupon(c *gin.Context) {
 	}
 
 	user.Points -= pointsRequired
-	if err := db.Save(&user).Error; err != nil {
+	if err := db.Omit("money").Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user points"})
 		return
 	}
@@ -91,6 +95,10 @@ func RedeemCoupon(c *gin.Context) {
 		return
 	}
 
+	if !authorizeUser(c, input.UserID) {
+		return
+	}
+
 	var userPromo models.UserPromo
 	if db.Where("promo_code = ? AND user_id = ?", input.PromoCode, input.UserID).First(&userPromo).RowsAffected == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
@@ -133,6 +141,10 @@ func GetUserCoupons(c *gin.Context) {
 		return
 	}
 
+	if !authorizeUserParam(c, userID) {
+		return
+	}
+
 	var userCoupons []models.UserPromo
 	if err := db.Where("user_id = ?", userID).Find(&userCoupons).Error; err != nil {
 		log.Printf("Failed to fetch user coupons: %v", err)
@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"log"
+	"main/auth"
+	"main/database"
+	"main/dto"
+	model "main/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var tailorTiers = map[string]bool{
+	model.TailorTierStandard: true,
+	model.TailorTierPremium:  true,
+}
+
+// AdminGetFeeRules lists the fee schedule, newest first. With active=true
+// only the rules in effect now are listed.
+func AdminGetFeeRules(c *gin.Context) {
+	db := database.GetInstance()
+
+	query := db.Model(&model.FeeRule{})
+	if c.Query("active") == "true" {
+		now := time.Now()
+		query = query.Where("effective_from <= ? AND (effective_until IS NULL OR effective_until > ?)", now, now)
+	}
+
+	var rules []model.FeeRule
+	if err := paginate(c, query).Order("effective_from desc, id desc").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch fee rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"feeRules": dto.NewFeeRules(rules)})
+}
+
+// FeeRuleInput adds a fee rule. Rate is in basis points; leaving
+// OutfitCategory, Kind or TailorTier empty applies the rule to any.
+// EffectiveFrom defaults to now.
+type FeeRuleInput struct {
+	OutfitCategory string     `json:"outfitCategory"`
+	Kind           string     `json:"kind"`
+	TailorTier     string     `json:"tailorTier"`
+	Rate           *int       `json:"rate" binding:"required"`
+	EffectiveFrom  *time.Time `json:"effectiveFrom"`
+	EffectiveUntil *time.Time `json:"effectiveUntil"`
+}
+
+func CreateFeeRule(c *gin.Context) {
+	db := database.GetInstance()
+	admin, _ := auth.GetPrincipal(c)
+
+	var input FeeRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if *input.Rate < 0 || *input.Rate > 10000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rate must be between 0 and 10000 basis points"})
+		return
+	}
+	if input.Kind != "" && input.Kind != model.FeeKindProduct && input.Kind != model.FeeKindRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be product or request"})
+		return
+	}
+	if input.TailorTier != "" && !tailorTiers[input.TailorTier] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown tailor tier"})
+		return
+	}
+
+	rule := model.FeeRule{
+		Kind:           input.Kind,
+		TailorTier:     input.TailorTier,
+		Rate:           *input.Rate,
+		EffectiveFrom:  time.Now(),
+		EffectiveUntil: input.EffectiveUntil,
+		CreatedBy:      admin.ID,
+	}
+	if input.EffectiveFrom != nil {
+		rule.EffectiveFrom = *input.EffectiveFrom
+	}
+	if rule.EffectiveUntil != nil && !rule.EffectiveUntil.After(rule.EffectiveFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "effectiveUntil must be after effectiveFrom"})
+		return
+	}
+
+	if input.OutfitCategory != "" {
+		var outfit model.Outfit
+		if db.Where("upper(category) = ?", strings.ToUpper(input.OutfitCategory)).Limit(1).Find(&outfit).RowsAffected == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown outfit category"})
+			return
+		}
+		rule.OutfitCategory = outfit.Category
+	}
+
+	if err := db.Create(&rule).Error; err != nil {
+		log.Printf("Failed to create fee rule: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create fee rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"feeRule": dto.NewFeeRule(rule)})
+}
+
+type EndFeeRuleInput struct {
+	EffectiveUntil *time.Time `json:"effectiveUntil"`
+}
+
+// EndFeeRule stops a fee rule from applying to orders placed from
+// effectiveUntil on, now by default. Orders placed before keep its rate.
+func EndFeeRule(c *gin.Context) {
+	db := database.GetInstance()
+
+	var input EndFeeRuleInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	until := time.Now()
+	if input.EffectiveUntil != nil {
+		until = *input.EffectiveUntil
+	}
+
+	var rule model.FeeRule
+	if err := db.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fee rule not found"})
+		return
+	}
+	if rule.EffectiveUntil != nil && rule.EffectiveUntil.Before(until) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The fee rule already ends before then"})
+		return
+	}
+	if until.Before(rule.EffectiveFrom) {
+		until = rule.EffectiveFrom
+	}
+
+	rule.EffectiveUntil = &until
+	if err := db.Model(&rule).Update("effective_until", rule.EffectiveUntil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update fee rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"feeRule": dto.NewFeeRule(rule)})
+}
+
+type TailorTierInput struct {
+	Tier string `json:"tier" binding:"required"`
+}
+
+// SetTailorTier moves a tailor to another tier of the fee schedule. Orders
+// keep the tier they were placed at, so only new ones are charged at the
+// new tier's rate.
+func SetTailorTier(c *gin.Context) {
+	db := database.GetInstance()
+
+	var input TailorTierInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !tailorTiers[input.Tier] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown tailor tier"})
+		return
+	}
+
+	result := db.Model(&model.Tailor{}).Where("id = ?", c.Param("id")).Update("tier", input.Tier)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tailor"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tailor not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tailor tier updated"})
+}
+
+// GetRevenueReport totals the platform fees kept on transactions settled
+// between from and to (inclusive, defaulting to the last 30 days), grouped
+// by day, month or tailor.
+func GetRevenueReport(c *gin.Context) {
+	db := database.GetInstance()
+
+	groupBy := c.DefaultQuery("groupBy", "day")
+	var key func(hold model.EscrowHold) (string, uint)
+	switch groupBy {
+	case "day":
+		key = func(hold model.EscrowHold) (string, uint) { return hold.SettledAt.Format("2006-01-02"), 0 }
+	case "month":
+		key = func(hold model.EscrowHold) (string, uint) { return hold.SettledAt.Format("2006-01"), 0 }
+	case "tailor":
+		key = func(hold model.EscrowHold) (string, uint) { return "", hold.TailorID }
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "groupBy must be day, month or tailor"})
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from, errFrom := reportDate(c.Query("from"), today.AddDate(0, 0, -29))
+	to, errTo := reportDate(c.Query("to"), today)
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must be formatted as YYYY-MM-DD"})
+		return
+	}
+
+	query := db.Where("status = ? AND settled_at >= ? AND settled_at < ?", model.HoldReleased, from, to.AddDate(0, 0, 1))
+	if tailorID := c.Query("tailorId"); tailorID != "" {
+		query = query.Where("tailor_id = ?", tailorID)
+	}
+
+	var holds []model.EscrowHold
+	if err := query.Find(&holds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build revenue report"})
+		return
+	}
+
+	rows := map[string]*dto.Revenue{}
+	total := dto.Revenue{}
+	for _, hold := range holds {
+		period, tailorID := key(hold)
+		id := period + "/" + strconv.FormatUint(uint64(tailorID), 10)
+		row, ok := rows[id]
+		if !ok {
+			row = &dto.Revenue{Period: period, TailorID: tailorID}
+			rows[id] = row
+		}
+		for _, r := range []*dto.Revenue{row, &total} {
+			r.Transactions++
+			r.Gross += hold.Amount
+			r.Fees += hold.FeeAmount
+		}
+	}
+
+	revenue := make([]dto.Revenue, 0, len(rows))
+	for _, row := range rows {
+		revenue = append(revenue, *row)
+	}
+	sort.Slice(revenue, func(i, j int) bool {
+		if groupBy == "tailor" {
+			return revenue[i].Fees > revenue[j].Fees || (revenue[i].Fees == revenue[j].Fees && revenue[i].TailorID < revenue[j].TailorID)
+		}
+		return revenue[i].Period < revenue[j].Period
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":    from.Format("2006-01-02"),
+		"to":      to.Format("2006-01-02"),
+		"revenue": revenue,
+		"total":   total,
+	})
+}
+
+func reportDate(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
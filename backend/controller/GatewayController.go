@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"main/checkout"
+	"main/config"
+	"main/database"
+	"main/dto"
+	"main/gateway"
+	"main/ledger"
+	model "main/models"
+	"main/qris"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errTopUpNotFound       = errors.New("top-up not found")
+	errTopUpAmountMismatch = errors.New("paid amount does not match the top-up")
+)
+
+// rupiahPerUnit converts wallet amounts, which are kept in thousands of
+// rupiah, into the rupiah a QRIS code charges.
+const rupiahPerUnit = 1000
+
+// startTopUp records the top-up, asks the provider to collect it and tells
+// the buyer how to pay.
+func startTopUp(c *gin.Context, user model.User, topUp model.TopUp) {
+	db := database.GetInstance()
+	provider := gateway.Get()
+
+	topUp.Provider = provider.Name()
+	topUp.Status = model.TopUpPending
+	if err := db.Create(&topUp).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start payment"})
+		return
+	}
+
+	intent, err := provider.CreateIntent(gateway.IntentRequest{
+		Reference:     topUpReference(topUp),
+		Amount:        topUp.Amount,
+		Method:        topUp.Method,
+		CustomerName:  user.Name,
+		CustomerEmail: user.Email,
+	})
+	if err == nil && topUp.Method == gateway.MethodQRIS {
+		topUp.QRISPayload, err = qrisPayload(topUp, intent)
+	}
+	if err != nil {
+		log.Printf("Failed to create payment intent for top-up %d: %v", topUp.ID, err)
+		db.Model(&topUp).Update("status", model.TopUpFailed)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "The payment provider is unavailable, please try again later"})
+		return
+	}
+
+	topUp.ProviderReference = intent.ProviderReference
+	topUp.VirtualAccountNumber = intent.VirtualAccountNumber
+	topUp.CheckoutURL = intent.CheckoutURL
+	topUp.ExpiresAt = &intent.ExpiresAt
+	if err := db.Omit("Transactions").Save(&topUp).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start payment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"topUp": dto.NewTopUp(topUp)})
+}
+
+func topUpReference(topUp model.TopUp) string {
+	return fmt.Sprintf("topup-%d", topUp.ID)
+}
+
+// qrisPayload builds the dynamic QRIS code for the top-up. The provider's
+// reference goes in the reference label, which its notification quotes back.
+func qrisPayload(topUp model.TopUp, intent gateway.Intent) (string, error) {
+	cfg := config.Get().QRIS
+	return qris.Payload(qris.Merchant{
+		ID:           cfg.MerchantID,
+		Name:         cfg.MerchantName,
+		City:         cfg.MerchantCity,
+		PostalCode:   cfg.PostalCode,
+		CategoryCode: cfg.CategoryCode,
+	}, qris.Payment{
+		Amount:     topUp.Amount * rupiahPerUnit,
+		BillNumber: topUpReference(topUp),
+		Reference:  intent.ProviderReference,
+	})
+}
+
+func GetTopUp(c *gin.Context) {
+	topUp, ok := findOwnTopUp(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"topUp": dto.NewTopUp(topUp)})
+}
+
+// GetTopUpQRIS serves a QRIS top-up's code as a PNG to scan.
+func GetTopUpQRIS(c *gin.Context) {
+	topUp, ok := findOwnTopUp(c)
+	if !ok {
+		return
+	}
+
+	if topUp.QRISPayload == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This payment has no QRIS code"})
+		return
+	}
+
+	png, err := qrcode.Encode(topUp.QRISPayload, qrcode.Medium, 512)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to draw QR code"})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+func findOwnTopUp(c *gin.Context) (model.TopUp, bool) {
+	db := database.GetInstance()
+
+	var topUp model.TopUp
+	if err := db.Preload("Transactions").First(&topUp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Top-up not found"})
+		return model.TopUp{}, false
+	}
+
+	if !authorizeUser(c, topUp.UserID) {
+		return model.TopUp{}, false
+	}
+	return topUp, true
+}
+
+// GatewayWebhook receives payment results from the provider. The body is
+// only trusted once its signature checks out.
+func GatewayWebhook(c *gin.Context) {
+	provider := gateway.Get()
+	if c.Param("provider") != provider.Name() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	handleWebhook(c, provider, c.Request.Header, body)
+}
+
+type MockPayInput struct {
+	Status string `json:"status"`
+}
+
+// MockGatewayPay plays the mock provider's side of a payment: it settles a
+// pending top-up and delivers the signed webhook the provider would send.
+// Only registered when payment_gateway.mock_pay_endpoint is turned on, for
+// development.
+func MockGatewayPay(c *gin.Context) {
+	db := database.GetInstance()
+
+	mock, ok := gateway.Get().(*gateway.MockProvider)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The mock provider is not enabled"})
+		return
+	}
+
+	input := MockPayInput{Status: gateway.EventPaid}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var topUp model.TopUp
+	if err := db.Where("provider = ? AND provider_reference = ?", mock.Name(), c.Param("reference")).First(&topUp).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Top-up not found"})
+		return
+	}
+
+	header, body, err := mock.Callback(gateway.Event{
+		ProviderReference: topUp.ProviderReference,
+		Reference:         topUpReference(topUp),
+		Status:            input.Status,
+		Amount:            topUp.Amount,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	handleWebhook(c, mock, header, body)
+}
+
+func handleWebhook(c *gin.Context, provider gateway.Provider, header http.Header, body []byte) {
+	event, err := provider.ParseWebhook(header, body)
+	if errors.Is(err, gateway.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	topUp, err := applyTopUpEvent(provider.Name(), event)
+	switch {
+	case errors.Is(err, errTopUpNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Top-up not found"})
+		return
+	case errors.Is(err, errTopUpAmountMismatch):
+		log.Printf("Top-up %d: provider reported %d paid, expected %d", topUp.ID, event.Amount, topUp.Amount)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Paid amount does not match the top-up"})
+		return
+	case err != nil:
+		log.Printf("Failed to apply webhook for top-up %s: %v", event.ProviderReference, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"topUp": dto.NewTopUp(topUp)})
+}
+
+// applyTopUpEvent settles a pending top-up, crediting the wallet when it was
+// paid and then paying for the checkout it was started from. Providers
+// retry webhooks, so an event for a top-up that is already settled changes
+// nothing.
+func applyTopUpEvent(provider string, event gateway.Event) (model.TopUp, error) {
+	db := database.GetInstance()
+
+	var topUp model.TopUp
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_reference = ?", provider, event.ProviderReference).
+			Limit(1).Find(&topUp).RowsAffected == 0 {
+			return errTopUpNotFound
+		}
+		if err := tx.Model(&topUp).Association("Transactions").Find(&topUp.Transactions); err != nil {
+			return err
+		}
+		if topUp.Status != model.TopUpPending {
+			return nil
+		}
+
+		switch event.Status {
+		case gateway.EventPaid:
+			if event.Amount != topUp.Amount {
+				return errTopUpAmountMismatch
+			}
+
+			description := fmt.Sprintf("Wallet top-up %d via %s", topUp.ID, topUp.Method)
+			if _, err := ledger.Transfer(tx, model.EntryTopUp, description, ledger.External, ledger.UserWallet(topUp.UserID), topUp.Amount); err != nil {
+				return err
+			}
+
+			payCheckout(tx, topUp)
+
+			now := time.Now()
+			topUp.Status = model.TopUpPaid
+			topUp.PaidAt = &now
+		case gateway.EventFailed:
+			topUp.Status = model.TopUpFailed
+		case gateway.EventExpired:
+			topUp.Status = model.TopUpExpired
+		}
+
+		return tx.Model(&topUp).Updates(map[string]interface{}{"status": topUp.Status, "paid_at": topUp.PaidAt}).Error
+	})
+	return topUp, err
+}
+
+// payCheckout pays for the transactions a checkout top-up was started for.
+// If that is no longer possible, say the buyer cancelled meanwhile, the
+// money simply stays in their wallet.
+func payCheckout(tx *gorm.DB, topUp model.TopUp) {
+	if len(topUp.Transactions) == 0 {
+		return
+	}
+
+	transactionIDs := make([]uint, 0, len(topUp.Transactions))
+	for _, transaction := range topUp.Transactions {
+		transactionIDs = append(transactionIDs, transaction.ID)
+	}
+
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		quote := checkout.Quote{ID: topUp.QuoteID, Total: topUp.Amount, PromoCode: topUp.PromoCode}
+		return payTransactions(tx, topUp.UserID, transactionIDs, quote)
+	})
+	if err != nil {
+		log.Printf("Top-up %d arrived but could not pay for transactions %v, leaving it in the wallet: %v", topUp.ID, transactionIDs, err)
+	}
+}
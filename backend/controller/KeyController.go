@@ -0,0 +1,13 @@
+package controller
+
+import (
+	"main/auth"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.JWKS())
+}
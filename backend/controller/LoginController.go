@@ -1,17 +1,56 @@
 package controller
 
 import (
-	"fmt"
+	"errors"
+	"log"
+	"main/auth"
 	"main/database"
+	"main/dto"
 	models "main/models"
+	"math"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const invalidCredentials = "Invalid email or password"
+
+// dummyHash is checked when no account matches the email, so a miss takes as
+// long as a wrong password and can't be told apart by timing.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("tailortech-dummy-password"), bcrypt.DefaultCost)
+
+func checkLoginAllowed(c *gin.Context, typ int, email string) bool {
+	wait := auth.LoginLockedFor(typ, email, c.ClientIP())
+	if wait <= 0 {
+		return true
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, please try again later"})
+	return false
+}
+
+func verifyLogin(c *gin.Context, typ int, email string, found bool, hash string, password string) bool {
+	if !found {
+		hash = string(dummyHash)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || !found {
+		if err := auth.RecordLoginFailure(typ, email, c.ClientIP()); err != nil {
+			log.Printf("Failed to record login failure: %v", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidCredentials})
+		return false
+	}
+
+	if err := auth.RecordLoginSuccess(typ, email); err != nil {
+		log.Printf("Failed to reset login failures: %v", err)
+	}
+	return true
+}
+
 func LoginHandler(c *gin.Context) {
 	type UserInput struct {
 		Email string
@@ -30,43 +69,30 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	db := database.GetInstance()
-
-	var user models.User
-
-	if db.Where("email = ?", input.Email).Find(&user).RowsAffected == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "You haven't registered yet!"})
-		return
-	}
-
 	if input.Pass == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input password!"})
 		return
 	}
 
-	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Pass)) != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Wrong password!"})
+	db := database.GetInstance()
+
+	if !checkLoginAllowed(c, auth.TypeUser, input.Email) {
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24 * 15).Unix(),
-		"typ": 0,
-	})
-
-	tokenString, err := token.SignedString([]byte("qwertyuiop"))
+	var user models.User
+	found := db.Where("email = ?", input.Email).Find(&user).RowsAffected != 0
 
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !verifyLogin(c, auth.TypeUser, input.Email, found, user.Password, input.Pass) {
 		return
 	}
 
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("auth", tokenString, 3600*24*15, "/", "localhost", false, false)
-
-	c.JSON(http.StatusOK, user)
+	if user.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your account has been suspended"})
+		return
+	}
 
+	completeLogin(c, user.ID, auth.TypeUser)
 }
 
 func TailorLoginHandler(c *gin.Context) {
@@ -87,110 +113,213 @@ func TailorLoginHandler(c *gin.Context) {
 		return
 	}
 
+	if input.Pass == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input password!"})
+		return
+	}
+
 	db := database.GetInstance()
 
+	if !checkLoginAllowed(c, auth.TypeTailor, input.Email) {
+		return
+	}
+
 	var user models.Tailor
+	found := db.Where("email = ?", input.Email).Find(&user).RowsAffected != 0
 
-	if db.Where("email = ?", input.Email).Find(&user).RowsAffected == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "You haven't registered yet!"})
+	if !verifyLogin(c, auth.TypeTailor, input.Email, found, user.Password, input.Pass) {
 		return
 	}
 
-	tailor := models.GetTailor(user.ID)
+	if user.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your account has been suspended"})
+		return
+	}
 
-	if input.Pass == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input password!"})
+	if requireSecondFactor(c, user.ID, auth.TypeTailor) {
 		return
 	}
 
-	// if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Pass)) != nil{
-	// 	c.JSON(http.StatusBadRequest, gin.H{"error": "Wrong password!"})
-	// 	return
-	// }
+	completeLogin(c, user.ID, auth.TypeTailor)
+}
+
+func AdminLoginHandler(c *gin.Context) {
+	type AdminInput struct {
+		Email string
+		Pass  string
+	}
+
+	var input AdminInput
 
-	if user.Password != input.Pass {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect password!"})
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24 * 15).Unix(),
-		"typ": 1,
-	})
+	if input.Email == "" || input.Pass == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please input email and password!"})
+		return
+	}
 
-	tokenString, err := token.SignedString([]byte("qwertyuiop"))
+	db := database.GetInstance()
 
+	if !checkLoginAllowed(c, auth.TypeAdmin, input.Email) {
+		return
+	}
+
+	var admin models.Admin
+	found := db.Where("email = ?", input.Email).Find(&admin).RowsAffected != 0
+
+	if !verifyLogin(c, auth.TypeAdmin, input.Email, found, admin.Password, input.Pass) {
+		return
+	}
+
+	if requireSecondFactor(c, admin.ID, auth.TypeAdmin) {
+		return
+	}
+
+	completeLogin(c, admin.ID, auth.TypeAdmin)
+}
+
+// requireSecondFactor answers with a two-factor challenge and returns true
+// when the account has TOTP enabled. The client then finishes the login at
+// /login/2fa with the returned MFAToken and a code.
+func requireSecondFactor(c *gin.Context, id uint, typ int) bool {
+	db := database.GetInstance()
+
+	if !auth.TwoFactorEnabled(db, id, typ) {
+		return false
+	}
+
+	mfaToken, err := auth.NewMFAToken(id, typ)
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
+		return true
+	}
+
+	c.JSON(http.StatusOK, gin.H{"MFARequired": true, "MFAToken": mfaToken})
+	return true
+}
+
+func TwoFactorLoginHandler(c *gin.Context) {
+	db := database.GetInstance()
+
+	type TwoFactorInput struct {
+		MFAToken string `binding:"required"`
+		Code     string `binding:"required"`
+	}
+
+	var input TwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("auth", tokenString, 3600*24*15, "/", "localhost", false, false)
+	principal, err := auth.ParseMFAToken(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Your login has expired, please log in again"})
+		return
+	}
 
-	c.JSON(http.StatusOK, tailor)
+	if twoFactorThrottled(c, principal) {
+		return
+	}
 
+	err = auth.VerifySecondFactor(db, principal.ID, principal.Type, input.Code)
+	if errors.Is(err, auth.ErrTwoFactorNotEnabled) {
+		err = auth.ErrInvalidTwoFactor
+	}
+	if !respondTwoFactorError(c, principal, err) {
+		return
+	}
+
+	completeLogin(c, principal.ID, principal.Type)
 }
 
-func GetUserFromJWT(c *gin.Context) {
+// completeLogin starts a session and answers with the account profile plus
+// the session tokens.
+func completeLogin(c *gin.Context, id uint, typ int) {
 	db := database.GetInstance()
-	tokenString, err := c.Cookie("auth")
 
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "No token found"})
+	tokens, ok := startSession(c, id, typ)
+	if !ok {
 		return
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte("qwertyuiop"), nil
-	})
+	switch typ {
+	case auth.TypeUser:
+		var user models.User
+		db.First(&user, id)
+		c.JSON(http.StatusOK, struct {
+			dto.User
+			AuthTokens
+		}{dto.NewUser(user), tokens})
+	case auth.TypeTailor:
+		c.JSON(http.StatusOK, struct {
+			dto.TailorAccount
+			AuthTokens
+		}{dto.NewTailorAccount(models.GetTailor(id)), tokens})
+	case auth.TypeAdmin:
+		var admin models.Admin
+		db.First(&admin, id)
+		c.JSON(http.StatusOK, struct {
+			dto.Admin
+			AuthTokens
+		}{dto.NewAdmin(admin), tokens})
+	}
+}
 
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to parse token"})
+func GetUserFromJWT(c *gin.Context) {
+	db := database.GetInstance()
+
+	principal, ok := auth.GetPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No token found"})
 		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has expired"})
+	if principal.Type == auth.TypeUser {
+		var user models.User
+		db.First(&user, principal.ID)
+		if user.ID == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 			return
 		}
-
-		typ, ok := claims["typ"].(float64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		c.JSON(http.StatusOK, dto.NewUser(user))
+	} else if principal.Type == auth.TypeTailor {
+		user := models.GetTailor(principal.ID)
+		if user.ID == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Tailor not found"})
 			return
 		}
-
-		if typ == 0 {
-			var user models.User
-			db.First(&user, claims["sub"])
-			if user.ID == 0 {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-				return
-			}
-			c.JSON(http.StatusOK, user)
-		} else if typ == 1 {
-			user := models.GetTailor(uint(claims["sub"].(float64)))
-			if user.ID == 0 {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Tailor not found"})
-				return
-			}
-			c.JSON(http.StatusOK, user)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unknown user type"})
+		c.JSON(http.StatusOK, dto.NewTailorAccount(user))
+	} else if principal.Type == auth.TypeAdmin {
+		var admin models.Admin
+		db.First(&admin, principal.ID)
+		if admin.ID == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin not found"})
+			return
 		}
+		c.JSON(http.StatusOK, dto.NewAdmin(admin))
 	} else {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unknown user type"})
 	}
 }
 
 func LogoutHandler(c *gin.Context) {
-	c.SetCookie("auth", "", -1, "/", "localhost", false, true)
+	if tokenString := auth.TokenFromRequest(c); tokenString != "" {
+		if principal, err := auth.ParseToken(tokenString); err == nil {
+			auth.RevokeSession(principal.SessionID)
+		}
+	}
+
+	if refreshToken := refreshTokenFromRequest(c); refreshToken != "" {
+		if session, err := auth.SessionFromRefreshToken(refreshToken); err == nil {
+			auth.RevokeSession(session.ID)
+		}
+	}
+
+	clearAuthCookies(c)
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
@@ -2,6 +2,7 @@ package controller
 
 import (
     "main/database"
+    "main/dto"
     model "main/models"
     "net/http"
     "fmt"
@@ -19,8 +20,12 @@ func CreateTopMeasurement(c *gin.Context) {
         return
     }
 
+    if !authorizeMeasurement(c, top.RequestID) {
+        return
+    }
+
     db.Create(&top)
-    c.JSON(http.StatusCreated, top)
+    c.JSON(http.StatusCreated, dto.NewTop(top))
 }
 
 func CreateBottomMeasurement(c *gin.Context) {
@@ -33,8 +38,12 @@ func CreateBottomMeasurement(c *gin.Context) {
         return
     }
 
+    if !authorizeMeasurement(c, bottom.RequestID) {
+        return
+    }
+
     db.Create(&bottom)
-    c.JSON(http.StatusCreated, bottom)
+    c.JSON(http.StatusCreated, dto.NewBottom(bottom))
 }
 
 func CreateDressMeasurement(c *gin.Context) {
@@ -47,8 +56,12 @@ if err := c.ShouldBindJSON(&dress); err != nil {
         return
     }
 
+    if !authorizeMeasurement(c, dress.RequestID) {
+        return
+    }
+
     db.Create(&dress)
-    c.JSON(http.StatusCreated, dress)
+    c.JSON(http.StatusCreated, dto.NewDress(dress))
 }
 
 func CreateSuitMeasurement(c *gin.Context) {
@@ -61,8 +74,12 @@ func CreateSuitMeasurement(c *gin.Context) {
         return
     }
 
+    if !authorizeMeasurement(c, suit.RequestID) {
+        return
+    }
+
     db.Create(&suit)
-    c.JSON(http.StatusCreated, suit)
+    c.JSON(http.StatusCreated, dto.NewSuit(suit))
 }
 
 func CreateToteBagMeasurement(c *gin.Context) {
@@ -75,7 +92,22 @@ func CreateToteBagMeasurement(c *gin.Context) {
         return
     }
 
+    if !authorizeMeasurement(c, toteBag.RequestID) {
+        return
+    }
+
     db.Create(&toteBag)
-    c.JSON(http.StatusCreated, toteBag)
+    c.JSON(http.StatusCreated, dto.NewToteBag(toteBag))
 }
 
+func authorizeMeasurement(c *gin.Context, requestID uint) bool {
+    db := database.GetInstance()
+
+    var request model.Request
+    if err := db.First(&request, requestID).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Request not found"})
+        return false
+    }
+
+    return authorizeUser(c, request.UserID)
+}
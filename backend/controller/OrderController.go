@@ -2,23 +2,28 @@ package controller
 
 import (
     "fmt"
+    "main/checkout"
     "main/database"
+    "main/dto"
+    "main/lifecycle"
     model "main/models"
-    "math"
+    "main/settlement"
     "net/http"
     "time"
 
     "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
 )
 
 type CreateProductOrderInput struct {
-    UserID     uint   
-    Name       string 
-    ProductIDs []uint 
-    Status     string 
-    TotalPrice uint  
+    UserID     uint
+    Name       string
+    QuoteToken string
 }
 
+// CreateProductOrder places an order for the products in a checkout quote,
+// one transaction per tailor, priced at the quote's total.
 func CreateProductOrder(c *gin.Context) {
     db := database.GetInstance()
     var input CreateProductOrderInput
@@ -29,44 +34,142 @@ func CreateProductOrder(c *gin.Context) {
         return
     }
 
-    var products []model.Product
-    if err := db.Where("id IN ?", input.ProductIDs).Find(&products).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Products not found"})
+    if !authorizeUser(c, input.UserID) {
         return
     }
-    for _, product := range products {
-        product.IsActive = false
-        db.Exec("delete from carts where user_id = ? and product_id = ?", input.UserID, product.ID)
-        if err := db.Save(&product).Error; err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-            return
-        }
+
+    quote, ok := verifyQuote(c, input.QuoteToken, input.UserID, checkout.KindOrder)
+    if !ok {
+        return
     }
 
-    transactions := make(map[uint]*model.Transaction)
-    for _, product := range products {
-        if transaction, ok := transactions[product.TailorID]; ok {
-            transaction.Products = append(transaction.Products, product)
-        } else {
-            transactions[product.TailorID] = &model.Transaction{
+    var transactionIDs []uint
+    err := db.Transaction(func(tx *gorm.DB) error {
+        if err := checkout.Use(tx, quote); err != nil {
+            return err
+        }
+
+        var products []model.Product
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            Where("id IN ? AND is_active = ?", quote.ProductIDs(), true).
+            Find(&products).Error; err != nil {
+            return err
+        }
+        if len(products) != len(quote.ProductIDs()) {
+            return checkout.ErrProductUnavailable
+        }
+        if err := tx.Model(&products).Update("is_active", false).Error; err != nil {
+            return err
+        }
+        if err := tx.Exec("delete from carts where user_id = ? and product_id in ?", input.UserID, quote.ProductIDs()).Error; err != nil {
+            return err
+        }
+
+        productsByID := make(map[uint]model.Product, len(products))
+        for _, product := range products {
+            productsByID[product.ID] = product
+        }
+
+        tiers, err := tailorTiersOf(tx, quote.TailorIDs())
+        if err != nil {
+            return err
+        }
+
+        shares := quoteShares(quote)
+        for _, tailorID := range quote.TailorIDs() {
+            transaction := &model.Transaction{
                 TransactionDate: time.Now(),
                 UserID:          input.UserID,
-                TailorID:        product.TailorID,
-                Products:        []model.Product{product},
-                Status:          input.Status,
-                TotalPrice:      input.TotalPrice,
+                TailorID:        tailorID,
+                Status:          model.StatusPendingPayment,
+                TotalPrice:      shares[tailorID],
+                TailorTier:      tiers[tailorID],
+                QuoteID:         quote.ID,
+            }
+            for _, line := range quote.Lines {
+                if line.TailorID == tailorID {
+                    transaction.Products = append(transaction.Products, productsByID[line.ProductID])
+                    transaction.Subtotal += uint(line.Price)
+                }
             }
+
+            if err := tx.Create(transaction).Error; err != nil {
+                return err
+            }
+            if err := lifecycle.Start(tx, *transaction, byPrincipal(c)); err != nil {
+                return err
+            }
+            transactionIDs = append(transactionIDs, transaction.ID)
         }
+        return nil
+    })
+    if respondCheckoutError(c, err) {
+        return
     }
 
-    for _, transaction := range transactions {
-        if err := db.Create(transaction).Error; err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-            return
+    c.JSON(http.StatusCreated, gin.H{"message": "Orders created successfully", "TransactionIDs": transactionIDs})
+}
+
+// tailorTiersOf looks up the tailors' tiers of the fee schedule, which their
+// transactions are charged at.
+func tailorTiersOf(tx *gorm.DB, tailorIDs []uint) (map[uint]string, error) {
+    var tailors []model.Tailor
+    if err := tx.Select("id", "tier").Where("id IN ?", tailorIDs).Find(&tailors).Error; err != nil {
+        return nil, err
+    }
+
+    tiers := make(map[uint]string, len(tailors))
+    for _, tailor := range tailors {
+        tiers[tailor.ID] = tailor.Tier
+    }
+    return tiers, nil
+}
+
+// quoteShares is what each tailor's transaction in the quote costs: the
+// quote's total split between them in proportion to their lines.
+func quoteShares(quote checkout.Quote) map[uint]uint {
+    tailorIDs := quote.TailorIDs()
+    subtotals := make([]uint, len(tailorIDs))
+    for i, tailorID := range tailorIDs {
+        for _, line := range quote.Lines {
+            if line.TailorID == tailorID {
+                subtotals[i] += uint(line.Price)
+            }
         }
     }
 
-    c.JSON(http.StatusCreated, gin.H{"message": "Orders created successfully"})
+    shares := make(map[uint]uint, len(tailorIDs))
+    for i, share := range splitTotal(uint(quote.Total), subtotals) {
+        shares[tailorIDs[i]] = share
+    }
+    return shares
+}
+
+// splitTotal divides the checkout total between one transaction per tailor
+// in proportion to their subtotals, so the transactions add up to exactly
+// what the buyer pays.
+func splitTotal(total uint, subtotals []uint) []uint {
+    shares := make([]uint, len(subtotals))
+    if len(subtotals) == 0 {
+        return shares
+    }
+
+    var sum uint
+    for _, subtotal := range subtotals {
+        sum += subtotal
+    }
+    if sum == 0 {
+        shares[0] = total
+        return shares
+    }
+
+    var allocated uint
+    for i, subtotal := range subtotals {
+        shares[i] = uint(uint64(total) * uint64(subtotal) / uint64(sum))
+        allocated += shares[i]
+    }
+    shares[len(shares)-1] += total - allocated
+    return shares
 }
 
 
@@ -75,13 +178,17 @@ func GetUserOrder(c *gin.Context){
 
     userID := c.Param("id")
 
+    if !authorizeUserParam(c, userID) {
+        return
+    }
+
     var tran []model.Transaction
     
     subquery := db.Table("tran_products").Select("transaction_id").Where("user_id = ?", userID)
 
     db.Preload("Products").Where("user_id = ?", userID).Where("id in (?)", subquery).Order("transaction_date desc").Find(&tran)
 
-    c.JSON(http.StatusOK, tran)
+    c.JSON(http.StatusOK, dto.NewTransactions(tran))
 }
 
 func GetTailorOrder(c *gin.Context){
@@ -89,44 +196,21 @@ func GetTailorOrder(c *gin.Context){
 
     tailorID := c.Param("id")
 
+    if !authorizeTailorParam(c, tailorID) {
+        return
+    }
+
     var tran []model.Transaction
 
     subquery := db.Table("tran_products").Select("transaction_id").Where("tailor_id = ?", tailorID)
 
-    db.Preload("Products").Where("tailor_id = ?", tailorID).Where("id in (?)", subquery).Order("transaction_date desc").Find(&tran)
+    db.Preload("Products").Where("tailor_id = ? AND status <> ?", tailorID, model.StatusPendingPayment).Where("id in (?)", subquery).Order("transaction_date desc").Find(&tran)
 
-    c.JSON(http.StatusOK, tran)
+    c.JSON(http.StatusOK, dto.NewTransactions(tran))
 }
 
 func UpdateOrderStatus(c *gin.Context) {
-    db := database.GetInstance()
-
-    type UpdateStatusInput struct {
-        TransactionID uint   `json:"transactionId" binding:"required"`
-        NewStatus     string `json:"newStatus" binding:"required"`
-    }
-
-    var input UpdateStatusInput
-
-    if err := c.ShouldBindJSON(&input); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-
-    var transaction model.Transaction
-    if db.Where("id = ?", input.TransactionID).First(&transaction).RowsAffected == 0 {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
-        return
-    }
-
-    transaction.Status = input.NewStatus
-
-    if err := db.Save(&transaction).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
-        return
-    }
-
-    c.JSON(http.StatusOK, gin.H{"message": "Status updated successfully"})
+    updateStatus(c)
 }
 
 type ConfirmReceivedRequest struct {
@@ -148,50 +232,26 @@ func HandleOrderReceived(c *gin.Context) {
         return
     }
 
-    if transaction.Status == "Finished" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction already marked as finished"})
+    if !authorizeUser(c, transaction.UserID) {
         return
     }
 
-    productAmount := 0.0
-    requestAmount := 0.0
-    pointsAwarded := 0
-
-    for _, product := range transaction.Products {
-        productAmount += float64(product.Price)
-    }
-
-    feePercentage := 0.05
-    tailorTechFee := (productAmount + requestAmount) * feePercentage
-    tailorAmount := (productAmount + requestAmount) - tailorTechFee
-
-    tailorAmount = math.Ceil(tailorAmount)
-
-    var tailor model.Tailor
-    if err := db.First(&tailor, transaction.TailorID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Tailor not found"})
-        return
-    }
-    tailor.Money += int(tailorAmount)
-    if err := db.Save(&tailor).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tailor balance"})
+    if transaction.Status == model.StatusFinished {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction already marked as finished"})
         return
     }
 
-    var user model.User
-    if err := db.First(&user, transaction.UserID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-        return
-    }
-    user.Points += pointsAwarded
-    if err := db.Save(&user).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user points"})
-        return
-    }
+    finishTransaction(c, transaction, 0)
+}
+
+// finishTransaction settles a transaction the buyer confirmed receiving.
+func finishTransaction(c *gin.Context, transaction model.Transaction, pointsAwarded int) {
+    db := database.GetInstance()
 
-    transaction.Status = "Finished"
-    if err := db.Save(&transaction).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction status"})
+    err := db.Transaction(func(tx *gorm.DB) error {
+        return settlement.Finish(tx, transaction.ID, byPrincipal(c), "", pointsAwarded)
+    })
+    if respondStatusError(c, err) {
         return
     }
 
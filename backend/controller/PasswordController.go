@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"main/auth"
+	"main/config"
+	"main/database"
+	"main/mail"
+	model "main/models"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const passwordResetLifetime = time.Hour
+
+func ForgotPassword(c *gin.Context) {
+	db := database.GetInstance()
+
+	type ForgotPasswordInput struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	var input ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The response is the same whether or not the address is registered, so
+	// this endpoint can't be used to find out who has an account. That holds
+	// for failures too: they are only logged.
+	response := gin.H{"message": "If this email is registered, a password reset link has been sent"}
+
+	var user model.User
+	if db.Where("email = ?", input.Email).Find(&user).RowsAffected == 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := auth.IssueUserToken(user.ID, user.Email, model.TokenPurposePasswordReset, passwordResetLifetime)
+	if err != nil {
+		log.Printf("Failed to issue password reset token for user %d: %v", user.ID, err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	link := appLink("/reset-password", token)
+	err = mail.Get().Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your TailorTech password",
+		Body: fmt.Sprintf("Hi %s,\n\nWe received a request to reset your TailorTech password. "+
+			"Open the link below within %d minutes to choose a new one:\n\n%s\n\n"+
+			"Or enter this code in the app: %s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n",
+			user.Name, int(passwordResetLifetime.Minutes()), link, token),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ResetPassword(c *gin.Context) {
+	db := database.GetInstance()
+
+	type ResetPasswordInput struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Confirm  string `json:"confirmPassword" binding:"required"`
+	}
+
+	var input ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Password != input.Confirm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passwords do not match"})
+		return
+	}
+
+	if passwordChecks := validatePassword(input.Password); len(passwordChecks) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": strings.Join(passwordChecks, ", ")})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := auth.ConsumeUserToken(tx, input.Token, model.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+
+		var user model.User
+		if err := tx.First(&user, userToken.UserID).Error; err != nil {
+			return auth.ErrInvalidUserToken
+		}
+
+		if err := user.SetPassword(input.Password); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&user).Update("password", user.Password).Error; err != nil {
+			return err
+		}
+
+		return auth.RevokeAllSessions(tx, user.ID, auth.TypeUser)
+	})
+
+	if errors.Is(err, auth.ErrInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This reset link is invalid or has expired"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to reset password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Your password has been reset, please log in again"})
+}
+
+func appLink(path string, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", strings.TrimRight(config.Get().PublicURL, "/"), path, url.QueryEscape(token))
+}
@@ -1,19 +1,28 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"main/checkout"
 	"main/database"
+	"main/escrow"
+	"main/gateway"
+	"main/ledger"
+	"main/lifecycle"
 	models "main/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PaymentRequest struct {
-	UserID        uint   `json:"userId"`
-	TotalAmount   int    `json:"totalAmount"`
-	PromoCode     string `json:"promoCode"`
-	PaymentMethod string `json:"paymentMethod"`
+	UserID         uint   `json:"userId"`
+	TransactionIDs []uint `json:"transactionIds" binding:"required"`
+	QuoteToken     string `json:"quoteToken" binding:"required"`
+	PaymentMethod  string `json:"paymentMethod"`
 }
 
 type PaymentResponse struct {
@@ -21,6 +30,17 @@ type PaymentResponse struct {
 	Message string `json:"message"`
 }
 
+var (
+	errTransactionNotFound = errors.New("transaction not found")
+	errAlreadyPaid         = errors.New("transaction already paid")
+	errAmountMismatch      = errors.New("transactions do not match the checkout quote")
+	errNoCoupon            = errors.New("coupon not available")
+)
+
+// ProcessPayment pays for transactions placed with a checkout quote, all of
+// them or only some, from the buyer's wallet, or starts collecting their
+// price through the payment provider when PaymentMethod names one of its
+// methods. The money is held in escrow until the buyer confirms receipt.
 func ProcessPayment(c *gin.Context) {
 	db := database.GetInstance()
 
@@ -30,65 +50,196 @@ func ProcessPayment(c *gin.Context) {
 		return
 	}
 
+	if !authorizeUser(c, paymentRequest.UserID) {
+		return
+	}
+
+	quote, ok := verifyQuote(c, paymentRequest.QuoteToken, paymentRequest.UserID, "")
+	if !ok {
+		return
+	}
+
+	switch method := paymentRequest.PaymentMethod; {
+	case method == "" || method == gateway.MethodWallet:
+	case gateway.IsProviderMethod(method):
+		startCheckoutPayment(c, paymentRequest, quote)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown payment method %q", method)})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return payTransactions(tx, paymentRequest.UserID, paymentRequest.TransactionIDs, quote)
+	})
+
+	if respondPaymentError(c, err) {
+		return
+	}
+
+	log.Printf("Payment processed successfully for user: %d", paymentRequest.UserID)
+
+	c.JSON(http.StatusOK, PaymentResponse{Success: true, Message: "Payment processed successfully"})
+}
+
+// startCheckoutPayment collects the transactions' price through the payment
+// provider. Once the provider confirms it, the money lands in the buyer's
+// wallet and pays for the transactions.
+func startCheckoutPayment(c *gin.Context, paymentRequest PaymentRequest, quote checkout.Quote) {
+	db := database.GetInstance()
+
 	var user models.User
-	if db.Where("id = ?", paymentRequest.UserID).First(&user).RowsAffected == 0 {
+	if err := db.First(&user, paymentRequest.UserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	if user.Money < paymentRequest.TotalAmount {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance to complete the payment"})
+	var transactions []models.Transaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transactions, _, err = lockUnpaid(tx, paymentRequest.UserID, paymentRequest.TransactionIDs, quote)
+		return err
+	})
+	if respondPaymentError(c, err) {
 		return
 	}
 
-	tx := db.Begin()
+	amount := 0
+	for _, transaction := range transactions {
+		amount += int(transaction.TotalPrice)
+	}
 
-	user.Money -= paymentRequest.TotalAmount
+	startTopUp(c, user, models.TopUp{
+		UserID:       user.ID,
+		Amount:       amount,
+		Method:       paymentRequest.PaymentMethod,
+		Transactions: transactions,
+		PromoCode:    quote.PromoCode,
+		QuoteID:      quote.ID,
+	})
+}
 
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user balance"})
-		return
+// lockUnpaid locks the buyer's transactions, along with the rest placed
+// with the quote, and checks the ones being paid are unpaid and each priced
+// at its share of the quote. Some may have been cancelled or paid on their
+// own, so they needn't be all of the quote. A quote rebuilt from a top-up
+// has no lines; its Total is what was collected for the transactions. It
+// also reports whether any other transaction of the quote was paid before.
+func lockUnpaid(tx *gorm.DB, userID uint, transactionIDs []uint, quote checkout.Quote) ([]models.Transaction, bool, error) {
+	var locked []models.Transaction
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("(id IN ? OR quote_id = ?) AND user_id = ?", transactionIDs, quote.ID, userID).
+		Order("id").
+		Find(&locked).Error; err != nil {
+		return nil, false, err
 	}
 
-	if paymentRequest.PromoCode != "" {
-		var userPromo models.UserPromo
-		if err := tx.Where("promo_code = ? AND user_id = ?", paymentRequest.PromoCode, paymentRequest.UserID).First(&userPromo).Error; err != nil {
-			tx.Rollback()
-			log.Printf("Failed to query user promo: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query user promo"})
-			return
+	wanted := uniqueIDs(transactionIDs)
+	var transactions []models.Transaction
+	var siblingIDs []uint
+	for _, transaction := range locked {
+		if wanted[transaction.ID] {
+			transactions = append(transactions, transaction)
+		} else {
+			siblingIDs = append(siblingIDs, transaction.ID)
 		}
+	}
+	if len(transactions) == 0 || len(transactions) != len(wanted) {
+		return nil, false, errTransactionNotFound
+	}
 
-		if userPromo.Quantity > 0 {
-			userPromo.Quantity--
-			if userPromo.Quantity == 0 {
-				if err := tx.Delete(&userPromo).Error; err != nil {
-					tx.Rollback()
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete promo code"})
-					return
-				}
-			} else {
-				if err := tx.Save(&userPromo).Error; err != nil {
-					tx.Rollback()
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update promo code quantity"})
-					return
-				}
-			}
-		} else {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon quantity is already zero"})
-			return
+	shares := quoteShares(quote)
+	total := 0
+	for _, transaction := range transactions {
+		if transaction.Status != models.StatusPendingPayment {
+			return nil, false, errAlreadyPaid
+		}
+		if transaction.QuoteID != quote.ID {
+			return nil, false, errAmountMismatch
 		}
+		if len(quote.Lines) > 0 && transaction.TotalPrice != shares[transaction.TailorID] {
+			return nil, false, errAmountMismatch
+		}
+		total += int(transaction.TotalPrice)
+	}
+	if len(quote.Lines) == 0 && total != quote.Total {
+		return nil, false, errAmountMismatch
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
+	var paidBefore int64
+	if len(siblingIDs) > 0 {
+		if err := tx.Model(&models.EscrowHold{}).Where("transaction_id IN ?", siblingIDs).Count(&paidBefore).Error; err != nil {
+			return nil, false, err
+		}
 	}
 
-	log.Printf("Payment processed successfully for user: %d", paymentRequest.UserID)
+	return transactions, paidBefore > 0, nil
+}
 
-	c.JSON(http.StatusOK, PaymentResponse{Success: true, Message: "Payment processed successfully"})
+// payTransactions pays for the transactions from the buyer's wallet into
+// escrow. The first payment for a quote uses up its promo code, if any.
+func payTransactions(tx *gorm.DB, userID uint, transactionIDs []uint, quote checkout.Quote) error {
+	transactions, paidBefore, err := lockUnpaid(tx, userID, transactionIDs, quote)
+	if err != nil {
+		return err
+	}
+
+	for _, transaction := range transactions {
+		if _, err := escrow.Hold(tx, transaction); err != nil {
+			return err
+		}
+		if _, err := lifecycle.Apply(tx, transaction.ID, models.StatusPaid, lifecycle.System, ""); err != nil {
+			return err
+		}
+	}
+
+	if quote.PromoCode != "" && !paidBefore {
+		var userPromo models.UserPromo
+		if err := tx.Where("promo_code = ? AND user_id = ?", quote.PromoCode, userID).First(&userPromo).Error; err != nil {
+			return errNoCoupon
+		}
+
+		if userPromo.Quantity <= 0 {
+			return errNoCoupon
+		}
+
+		userPromo.Quantity--
+		if userPromo.Quantity == 0 {
+			return tx.Delete(&userPromo).Error
+		}
+		return tx.Save(&userPromo).Error
+	}
+
+	return nil
+}
+
+// respondPaymentError answers with the error a payment failed with, and
+// reports whether there was one.
+func respondPaymentError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, errTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	case errors.Is(err, errAlreadyPaid), errors.Is(err, escrow.ErrAlreadyHeld):
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction has already been paid"})
+	case errors.Is(err, errAmountMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "These transactions were not placed with this checkout quote"})
+	case errors.Is(err, ledger.ErrInsufficientFunds):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance to complete the payment"})
+	case errors.Is(err, errNoCoupon):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon is not available"})
+	default:
+		log.Printf("Failed to process payment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process payment"})
+	}
+	return true
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	unique := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	return unique
 }
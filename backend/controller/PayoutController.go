@@ -0,0 +1,368 @@
+package controller
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"main/auth"
+	"main/config"
+	"main/database"
+	"main/dto"
+	"main/ledger"
+	model "main/models"
+	"main/payout"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BankAccountInput struct {
+	BankCode      string `json:"bankCode" binding:"required"`
+	AccountNumber string `json:"accountNumber" binding:"required"`
+	AccountHolder string `json:"accountHolder" binding:"required"`
+}
+
+var (
+	bankCodePattern      = regexp.MustCompile(`^[A-Z0-9]{2,16}$`)
+	accountNumberPattern = regexp.MustCompile(`^[0-9]{5,32}$`)
+	accountHolderPattern = regexp.MustCompile(`^[\p{L} .'-]{1,100}$`)
+)
+
+func GetBankAccounts(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	var bankAccounts []model.BankAccount
+	if err := db.Where("tailor_id = ?", principal.ID).Order("id").Find(&bankAccounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bank accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bankAccounts": dto.NewBankAccounts(bankAccounts)})
+}
+
+func AddBankAccount(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	var input BankAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bankAccount := model.BankAccount{
+		TailorID:      principal.ID,
+		BankCode:      strings.ToUpper(strings.TrimSpace(input.BankCode)),
+		AccountNumber: strings.ReplaceAll(strings.TrimSpace(input.AccountNumber), " ", ""),
+		AccountHolder: strings.TrimSpace(input.AccountHolder),
+	}
+
+	if !bankCodePattern.MatchString(bankAccount.BankCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bank code must be 2 to 16 letters or digits"})
+		return
+	}
+	if !accountNumberPattern.MatchString(bankAccount.AccountNumber) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Account number must be 5 to 32 digits"})
+		return
+	}
+	if !accountHolderPattern.MatchString(bankAccount.AccountHolder) || len(bankAccount.AccountHolder) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Account holder must be 1 to 100 letters, spaces or . ' -"})
+		return
+	}
+
+	if err := db.Create(&bankAccount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add bank account"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"bankAccount": dto.NewBankAccount(bankAccount)})
+}
+
+// RemoveBankAccount only hides the account from new requests. Withdrawals
+// already filed against it are still paid there.
+func RemoveBankAccount(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	result := db.Where("id = ? AND tailor_id = ?", c.Param("id"), principal.ID).Delete(&model.BankAccount{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove bank account"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bank account not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bank account removed"})
+}
+
+// withdrawalLimits reads the configured bounds on withdrawal requests.
+func withdrawalLimits() payout.Limits {
+	cfg := config.Get()
+	return payout.Limits{
+		Minimum: cfg.WithdrawalMinimum,
+		Maximum: cfg.WithdrawalMaximum,
+		Daily:   cfg.WithdrawalDailyLimit,
+	}
+}
+
+// withdrawals is the query for withdrawal requests with the bank account
+// they are paid to, including accounts the tailor has since removed.
+func withdrawals(db *gorm.DB) *gorm.DB {
+	return db.Model(&model.WithdrawalRequest{}).Preload("BankAccount", func(tx *gorm.DB) *gorm.DB {
+		return tx.Unscoped()
+	})
+}
+
+func GetWithdrawals(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	var requests []model.WithdrawalRequest
+	if err := paginate(c, withdrawals(db)).Where("tailor_id = ?", principal.ID).Order("id desc").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch withdrawals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"withdrawals": dto.NewWithdrawals(requests)})
+}
+
+func CancelWithdrawal(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid withdrawal ID"})
+		return
+	}
+
+	var request model.WithdrawalRequest
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.WithdrawalRequest{}).Where("id = ? AND tailor_id = ?", id, principal.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return payout.ErrNotFound
+		}
+
+		request, err = payout.Cancel(tx, uint(id))
+		return err
+	})
+	if respondPayoutError(c, err) {
+		return
+	}
+
+	respondWithdrawal(c, request.ID)
+}
+
+func AdminGetWithdrawals(c *gin.Context) {
+	db := database.GetInstance()
+
+	query := withdrawals(db)
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if tailorID := c.Query("tailorId"); tailorID != "" {
+		query = query.Where("tailor_id = ?", tailorID)
+	}
+
+	var requests []model.WithdrawalRequest
+	if err := paginate(c, query).Order("id").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch withdrawals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"withdrawals": dto.NewWithdrawals(requests)})
+}
+
+func ApproveWithdrawal(c *gin.Context) {
+	reviewWithdrawal(c, func(tx *gorm.DB, id, adminID uint) (model.WithdrawalRequest, error) {
+		return payout.Approve(tx, id, adminID)
+	})
+}
+
+type RejectWithdrawalInput struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+func RejectWithdrawal(c *gin.Context) {
+	var input RejectWithdrawalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reviewWithdrawal(c, func(tx *gorm.DB, id, adminID uint) (model.WithdrawalRequest, error) {
+		return payout.Reject(tx, id, adminID, input.Reason)
+	})
+}
+
+func reviewWithdrawal(c *gin.Context, review func(tx *gorm.DB, id, adminID uint) (model.WithdrawalRequest, error)) {
+	db := database.GetInstance()
+	admin, _ := auth.GetPrincipal(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid withdrawal ID"})
+		return
+	}
+
+	var request model.WithdrawalRequest
+	err = db.Transaction(func(tx *gorm.DB) error {
+		request, err = review(tx, uint(id), admin.ID)
+		return err
+	})
+	if respondPayoutError(c, err) {
+		return
+	}
+
+	respondWithdrawal(c, request.ID)
+}
+
+// PaidWithdrawal pairs a withdrawal with the bank's reference for its
+// transfer.
+type PaidWithdrawal struct {
+	ID        uint   `json:"id" binding:"required"`
+	Reference string `json:"reference" binding:"required"`
+}
+
+type MarkWithdrawalsPaidInput struct {
+	Withdrawals []PaidWithdrawal `json:"withdrawals" binding:"required,min=1,dive"`
+}
+
+// MarkWithdrawalsPaid records a finished bulk transfer. Either every listed
+// withdrawal is marked paid or, if any can't be, none are.
+func MarkWithdrawalsPaid(c *gin.Context) {
+	db := database.GetInstance()
+
+	var input MarkWithdrawalsPaidInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var failed uint
+	var ids []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, withdrawal := range input.Withdrawals {
+			if _, err := payout.MarkPaid(tx, withdrawal.ID, withdrawal.Reference); err != nil {
+				failed = withdrawal.ID
+				return err
+			}
+			ids = append(ids, withdrawal.ID)
+		}
+		return nil
+	})
+	if errors.Is(err, payout.ErrNotFound) || errors.Is(err, payout.ErrWrongStatus) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Withdrawal %d is not approved and awaiting payment, nothing was marked paid", failed)})
+		return
+	}
+	if respondPayoutError(c, err) {
+		return
+	}
+
+	var requests []model.WithdrawalRequest
+	withdrawals(db).Where("id IN ?", ids).Order("id").Find(&requests)
+
+	c.JSON(http.StatusOK, gin.H{"withdrawals": dto.NewWithdrawals(requests)})
+}
+
+// ExportWithdrawals writes the approved, unpaid withdrawals as a CSV file
+// for the bank's bulk-transfer upload.
+func ExportWithdrawals(c *gin.Context) {
+	db := database.GetInstance()
+
+	var requests []model.WithdrawalRequest
+	if err := withdrawals(db).Where("status = ?", model.WithdrawalApproved).Order("id").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch withdrawals"})
+		return
+	}
+
+	filename := fmt.Sprintf("withdrawals-%s.csv", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"withdrawal_id", "tailor_id", "bank_code", "account_number", "account_holder", "amount", "approved_at"})
+	for _, request := range requests {
+		approvedAt := ""
+		if request.ReviewedAt != nil {
+			approvedAt = request.ReviewedAt.Format(time.RFC3339)
+		}
+		writer.Write([]string{
+			strconv.FormatUint(uint64(request.ID), 10),
+			strconv.FormatUint(uint64(request.TailorID), 10),
+			spreadsheetCell(request.BankAccount.BankCode),
+			spreadsheetCell(request.BankAccount.AccountNumber),
+			spreadsheetCell(request.BankAccount.AccountHolder),
+			strconv.Itoa(request.Amount),
+			approvedAt,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Failed to write withdrawal export: %v", err)
+	}
+}
+
+// spreadsheetCell keeps a value a spreadsheet would read as a formula from
+// being run when finance opens the export, by quoting it as text.
+func spreadsheetCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func respondWithdrawal(c *gin.Context, id uint) {
+	db := database.GetInstance()
+
+	var request model.WithdrawalRequest
+	if err := withdrawals(db).First(&request, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch withdrawal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"withdrawal": dto.NewWithdrawal(request)})
+}
+
+// respondPayoutError answers with the error a payout step failed with, and
+// reports whether there was one.
+func respondPayoutError(c *gin.Context, err error) bool {
+	limits := withdrawalLimits()
+
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, payout.ErrBelowMinimum):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("The minimum withdrawal is %d", limits.Minimum)})
+	case errors.Is(err, payout.ErrAboveMaximum):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("The maximum withdrawal is %d", limits.Maximum)})
+	case errors.Is(err, payout.ErrDailyLimit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("You can withdraw at most %d in 24 hours", limits.Daily)})
+	case errors.Is(err, ledger.ErrInsufficientFunds):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance"})
+	case errors.Is(err, payout.ErrNoBankAccount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bank account not found"})
+	case errors.Is(err, payout.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Withdrawal not found"})
+	case errors.Is(err, payout.ErrWrongStatus):
+		c.JSON(http.StatusConflict, gin.H{"error": "The withdrawal can't be changed in its current state"})
+	default:
+		log.Printf("Failed to process withdrawal: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process withdrawal"})
+	}
+	return true
+}
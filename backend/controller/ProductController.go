@@ -2,6 +2,7 @@ package controller
 
 import (
 	"main/database"
+	"main/dto"
 	models "main/models"
 	"net/http"
 	"strings"
@@ -10,18 +11,9 @@ import (
 )
 
 func GetAllProduct(c *gin.Context) {
-	type GetProduct struct {
-		ID      int
-		Product string
-		Tailor  string
-		Desc    string
-		Price   int
-		ImgUrl  string
-		Size    string
-	}
 	db := database.GetInstance()
 
-	var products []GetProduct
+	var products []dto.ProductListing
 	query := c.Query("query")
 
 	sql := "SELECT products.id, products.name as product, tailors.name as tailor, products.desc, products.price, products.img_url, products.size " +
@@ -46,18 +38,9 @@ func GetAllProduct(c *gin.Context) {
 }
 
 func GetTailorProducts(c *gin.Context) {
-	type GetProduct struct {
-		ID      int
-		Product string
-		Tailor  string
-		Desc    string
-		Price   int
-		ImgUrl  string
-		Size    string
-	}
 	db := database.GetInstance()
 
-	var products []GetProduct
+	var products []dto.ProductListing
 	tailorID := c.Query("tailor_id")
 
 	if tailorID == "" {
@@ -77,18 +60,9 @@ func GetTailorProducts(c *gin.Context) {
 }
 
 func GetInactiveTailorProducts(c *gin.Context) {
-	type GetProduct struct {
-		ID      int
-		Product string
-		Tailor  string
-		Desc    string
-		Price   int
-		ImgUrl  string
-		Size    string
-	}
 	db := database.GetInstance()
 
-	var products []GetProduct
+	var products []dto.ProductListing
 	tailorID := c.Query("tailor_id")
 
 	if tailorID == "" {
@@ -96,6 +70,10 @@ func GetInactiveTailorProducts(c *gin.Context) {
 		return
 	}
 
+	if !authorizeTailorParam(c, tailorID) {
+		return
+	}
+
 	sql := "SELECT products.id, products.name as product, tailors.name as tailor, products.desc, products.price, products.img_url, products.size " +
 		"FROM products " +
 		"LEFT JOIN tailors ON products.tailor_id = tailors.id " +
@@ -118,6 +96,10 @@ func RemoveProduct(c *gin.Context) {
 		return
 	}
 
+	if !authorizeTailor(c, product.TailorID) {
+		return
+	}
+
 	product.IsActive = false
 	if err := db.Save(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove product"})
@@ -138,6 +120,10 @@ func ActivateProduct(c *gin.Context) {
 		return
 	}
 
+	if !authorizeTailor(c, product.TailorID) {
+		return
+	}
+
 	product.IsActive = true
 	if err := db.Save(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate product"})
@@ -166,6 +152,10 @@ func AddProduct(c *gin.Context) {
 		return
 	}
 
+	if !authorizeTailor(c, request.TailorID) {
+		return
+	}
+
 	product := models.Product{
 		Name:     request.Name,
 		TailorID: request.TailorID,
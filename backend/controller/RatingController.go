@@ -28,6 +28,10 @@ func SubmitRating(c *gin.Context) {
 		return
 	}
 
+	if !authorizeUser(c, transaction.UserID) {
+		return
+	}
+
 	if transaction.Status != "Finished" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction is not finished"})
 		return
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"log"
 	"main/database"
 	model "main/models"
 	"net/http"
@@ -8,7 +9,6 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func Register(c *gin.Context) {
@@ -29,14 +29,8 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	if len(input.Name) < 5 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be more than 5 characters"})
-		return
-	}
-
-	regex := regexp.MustCompile(`^[a-zA-Z\\s]+$`)
-	if !regex.MatchString(input.Name) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must not contain symbols or numbers"})
+	if msg := validateName(input.Name); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
 	}
 
@@ -56,57 +50,155 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	var passwordChecks []string
+	if passwordChecks := validatePassword(input.Password); len(passwordChecks) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": strings.Join(passwordChecks, ", ")})
+		return
+	}
 
-	if len(input.Password) < 8 {
-		passwordChecks = append(passwordChecks, "Password must be at least 8 characters long")
+	if msg := validatePhoneNumber(input.PhoneNumber); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
 	}
 
-	if !containsUppercase(input.Password) {
-		passwordChecks = append(passwordChecks, "Password must contain at least one uppercase letter")
+	insert := model.User{
+		Name:        input.Name,
+		Email:       input.Email,
+		PhoneNumber: input.PhoneNumber,
+		Address:     input.Address,
 	}
 
-	if !containsLowercase(input.Password) {
-		passwordChecks = append(passwordChecks, "Password must contain at least one lowercase letter")
+	if err := insert.SetPassword(input.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
+		return
 	}
 
-	if !containsDigit(input.Password) {
-		passwordChecks = append(passwordChecks, "Password must contain at least one digit")
+	if err := db.Create(&insert).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register"})
+		return
 	}
 
-	if !containsSpecialChar(input.Password) {
-		passwordChecks = append(passwordChecks, "Password must contain at least one special character")
+	if err := sendVerificationEmail(insert); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "You have successfully registered! Please check your email to verify your address."})
+}
+
+func RegisterTailor(c *gin.Context) {
+	db := database.GetInstance()
+	type RegisterTailorInput struct {
+		Name     string
+		Email    string
+		Password string
+		Confirm  string
+		Address  string
+		ImgUrl   string
+	}
+
+	var input RegisterTailorInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateName(input.Name); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if !model.IsValidEmail(input.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please provide a valid email address"})
+		return
+	}
+
+	var query model.Tailor
+	if db.Where("email = ?", input.Email).Find(&query).RowsAffected != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This email has been registered"})
+		return
 	}
 
-	if len(passwordChecks) > 0 {
+	if input.Password != input.Confirm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password doesn't match"})
+		return
+	}
+
+	if passwordChecks := validatePassword(input.Password); len(passwordChecks) > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": strings.Join(passwordChecks, ", ")})
 		return
 	}
 
-	if len(input.PhoneNumber) < 10 || len(input.PhoneNumber) > 13 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Phone number must be between 10 and 13 digits long"})
+	if input.Address == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please provide your address"})
 		return
 	}
 
-	
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-	if err != nil {
+	insert := model.Tailor{
+		Name:    input.Name,
+		Email:   input.Email,
+		Address: input.Address,
+		ImgUrl:  input.ImgUrl,
+	}
+
+	if err := insert.SetPassword(input.Password); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
 		return
 	}
 
-	insert := model.User{
-		Name:        input.Name,
-		Email:       input.Email,
-		Password:    string(hashedPassword),
-		PhoneNumber: input.PhoneNumber,
-		Address:     input.Address,
+	if err := db.Create(&insert).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register tailor"})
+		return
 	}
-	db.Create(&insert)
 
 	c.JSON(http.StatusOK, gin.H{"message": "You have successfully registered!"})
 }
 
+func validateName(name string) string {
+	if len(name) < 5 {
+		return "Name must be more than 5 characters"
+	}
+
+	regex := regexp.MustCompile(`^[a-zA-Z\s]+$`)
+	if !regex.MatchString(name) {
+		return "Name must not contain symbols or numbers"
+	}
+
+	return ""
+}
+
+func validatePassword(password string) []string {
+	var passwordChecks []string
+
+	if len(password) < 8 {
+		passwordChecks = append(passwordChecks, "Password must be at least 8 characters long")
+	}
+
+	if !containsUppercase(password) {
+		passwordChecks = append(passwordChecks, "Password must contain at least one uppercase letter")
+	}
+
+	if !containsLowercase(password) {
+		passwordChecks = append(passwordChecks, "Password must contain at least one lowercase letter")
+	}
+
+	if !containsDigit(password) {
+		passwordChecks = append(passwordChecks, "Password must contain at least one digit")
+	}
+
+	if !containsSpecialChar(password) {
+		passwordChecks = append(passwordChecks, "Password must contain at least one special character")
+	}
+
+	return passwordChecks
+}
+
+func validatePhoneNumber(phoneNumber string) string {
+	if len(phoneNumber) < 10 || len(phoneNumber) > 13 {
+		return "Phone number must be between 10 and 13 digits long"
+	}
+	return ""
+}
+
 func containsUppercase(s string) bool {
 	for _, char := range s {
 		if 'A' <= char && char <= 'Z' {
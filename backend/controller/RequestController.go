@@ -2,26 +2,28 @@ package controller
 
 import (
     "fmt"
+    "main/checkout"
     "main/database"
+    "main/dto"
+    "main/lifecycle"
     model "main/models"
-    "math"
+    "main/settlement"
     "net/http"
     "time"
 
     "github.com/gin-gonic/gin"
+    "gorm.io/gorm"
 )
 
 type CreateRequestInput struct {
-    UserID      uint
-    Name        string
-    Desc        string
-    Price       uint
-    RequestType string
-    TailorID    uint
-    Status      string
-    TotalPrice  uint
+    UserID     uint
+    Name       string
+    Desc       string
+    QuoteToken string
 }
 
+// CreateUserRequest files a custom request to the tailor in a checkout
+// quote, at the tailor's quoted price.
 func CreateUserRequest(c *gin.Context) {
     db := database.GetInstance()
     var input CreateRequestInput
@@ -32,38 +34,61 @@ func CreateUserRequest(c *gin.Context) {
         return
     }
 
-    var reqID uint
-    db.Raw("select id from outfits where upper(category) like ?", input.RequestType).Scan(&reqID)
-
-    request := model.Request{
-        UserID:      input.UserID,
-        Name:        input.Name,
-        Desc:        input.Desc,
-        Price:       input.Price,
-        RequestType: reqID,
-        TailorID:    input.TailorID,
+    if !authorizeUser(c, input.UserID) {
+        return
     }
 
-    if err := db.Create(&request).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    quote, ok := verifyQuote(c, input.QuoteToken, input.UserID, checkout.KindRequest)
+    if !ok {
         return
     }
+    line := quote.Lines[0]
 
-    transaction := model.Transaction{
-        TransactionDate: time.Now(),
-        UserID:          input.UserID,
-        TailorID:        input.TailorID,
-        Requests:        []model.Request{request},
-        Status:          input.Status,
-        TotalPrice:      input.TotalPrice,
-    }
+    var request model.Request
+    var transaction model.Transaction
+    err := db.Transaction(func(tx *gorm.DB) error {
+        if err := checkout.Use(tx, quote); err != nil {
+            return err
+        }
 
-    if err := db.Create(&transaction).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        request = model.Request{
+            UserID:      input.UserID,
+            Name:        input.Name,
+            Desc:        input.Desc,
+            Price:       uint(line.Price),
+            RequestType: line.OutfitID,
+            TailorID:    line.TailorID,
+        }
+        if err := tx.Create(&request).Error; err != nil {
+            return err
+        }
+
+        tiers, err := tailorTiersOf(tx, []uint{line.TailorID})
+        if err != nil {
+            return err
+        }
+
+        transaction = model.Transaction{
+            TransactionDate: time.Now(),
+            UserID:          input.UserID,
+            TailorID:        line.TailorID,
+            Requests:        []model.Request{request},
+            Status:          model.StatusPendingPayment,
+            TotalPrice:      uint(quote.Total),
+            Subtotal:        uint(line.Price),
+            TailorTier:      tiers[line.TailorID],
+            QuoteID:         quote.ID,
+        }
+        if err := tx.Create(&transaction).Error; err != nil {
+            return err
+        }
+        return lifecycle.Start(tx, transaction, byPrincipal(c))
+    })
+    if respondCheckoutError(c, err) {
         return
     }
 
-    c.JSON(http.StatusCreated, gin.H{"ID": request.ID})
+    c.JSON(http.StatusCreated, gin.H{"ID": request.ID, "TransactionID": transaction.ID})
 }
 
 func GetUserRequest(c *gin.Context){
@@ -71,13 +96,17 @@ func GetUserRequest(c *gin.Context){
 
     userID := c.Param("id")
 
+    if !authorizeUserParam(c, userID) {
+        return
+    }
+
     subquery := db.Table("tran_requests").Select("transaction_id").Where("user_id = ?", userID)
 
     var tran []model.Transaction
 
     db.Preload("Requests").Where("user_id = ?", userID).Where("id in (?)", subquery).Order("transaction_date desc").Find(&tran)
 
-    c.JSON(http.StatusOK, tran)
+    c.JSON(http.StatusOK, dto.NewTransactions(tran))
 }
 
 func GetTailorRequest(c *gin.Context){
@@ -85,44 +114,21 @@ func GetTailorRequest(c *gin.Context){
 
     tailorID := c.Param("id")
 
+    if !authorizeTailorParam(c, tailorID) {
+        return
+    }
+
     var tran []model.Transaction
 
     subquery := db.Table("tran_requests").Select("transaction_id").Where("tailor_id = ?", tailorID)
 
-    db.Preload("Requests").Preload("Requests.Top").Preload("Requests.Bottom").Preload("Requests.Dress").Preload("Requests.Suit").Preload("Requests.ToteBag").Where("tailor_id = ?", tailorID).Where("id in (?)", subquery).Order("transaction_date desc").Find(&tran)
+    db.Preload("Requests").Preload("Requests.Top").Preload("Requests.Bottom").Preload("Requests.Dress").Preload("Requests.Suit").Preload("Requests.ToteBag").Where("tailor_id = ? AND status <> ?", tailorID, model.StatusPendingPayment).Where("id in (?)", subquery).Order("transaction_date desc").Find(&tran)
 
-    c.JSON(http.StatusOK, tran)
+    c.JSON(http.StatusOK, dto.NewTransactions(tran))
 }
 
 func UpdateRequestStatus(c *gin.Context) {
-    db := database.GetInstance()
-
-    type UpdateStatusInput struct {
-        TransactionID uint   `json:"transactionId" binding:"required"`
-        NewStatus     string `json:"newStatus" binding:"required"`
-    }
-
-    var input UpdateStatusInput
-
-    if err := c.ShouldBindJSON(&input); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-
-    var transaction model.Transaction
-    if db.Where("id = ?", input.TransactionID).First(&transaction).RowsAffected == 0 {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
-        return
-    }
-
-    transaction.Status = input.NewStatus
-
-    if err := db.Save(&transaction).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
-        return
-    }
-
-    c.JSON(http.StatusOK, gin.H{"message": "Status updated successfully"})
+    updateStatus(c)
 }
 
 func HandleRequestReceived(c *gin.Context) {
@@ -140,59 +146,21 @@ func HandleRequestReceived(c *gin.Context) {
         return
     }
 
-    if transaction.Status == "Finished" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction already marked as finished"})
-        return
-    }
-
-    productAmount := 0.0
-    requestAmount := 0.0
-    pointsAwarded := 0
-
-    for _, request := range transaction.Requests {
-        var tailorPrice model.TailorPrice
-        if err := db.Where("tailor_id = ? AND outfit_id = ?", transaction.TailorID, request.RequestType).First(&tailorPrice).Error; err != nil {
-            c.JSON(http.StatusNotFound, gin.H{"error": "Tailor price not found for request"})
-            return
-        }
-        requestAmount += float64(tailorPrice.Price)
-        pointsAwarded += int(tailorPrice.Price) / 15 
-    }
-
-    feePercentage := 0.05
-    tailorTechFee := (productAmount + requestAmount) * feePercentage
-    tailorAmount := (productAmount + requestAmount) - tailorTechFee
-
-    tailorAmount = math.Ceil(tailorAmount)
-
-    var tailor model.Tailor
-    if err := db.First(&tailor, transaction.TailorID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Tailor not found"})
-        return
-    }
-    tailor.Money += int(tailorAmount)
-    if err := db.Save(&tailor).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tailor balance"})
+    if !authorizeUser(c, transaction.UserID) {
         return
     }
 
-    var user model.User
-    if err := db.First(&user, transaction.UserID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-        return
-    }
-    user.Points += pointsAwarded
-    if err := db.Save(&user).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user points"})
+    if transaction.Status == model.StatusFinished {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction already marked as finished"})
         return
     }
 
-    transaction.Status = "Finished"
-    if err := db.Save(&transaction).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction status"})
+    pointsAwarded, err := settlement.Points(db, transaction)
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Tailor price not found for request"})
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Transaction marked as finished, tailor's balance updated, and user points awarded"})
+    finishTransaction(c, transaction, pointsAwarded)
 }
 
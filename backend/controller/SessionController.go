@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"errors"
+	"main/auth"
+	"main/config"
+	"main/database"
+	model "main/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthTokens is returned in the body of login and refresh responses so
+// clients that can't rely on cookies can send the access token as a Bearer
+// token instead.
+type AuthTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+func startSession(c *gin.Context, id uint, typ int) (AuthTokens, bool) {
+	session, refreshToken, err := auth.CreateSession(id, typ, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return AuthTokens{}, false
+	}
+
+	tokenString, err := auth.NewToken(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
+		return AuthTokens{}, false
+	}
+
+	setAuthCookies(c, tokenString, refreshToken)
+	return newAuthTokens(tokenString, refreshToken), true
+}
+
+func newAuthTokens(tokenString string, refreshToken string) AuthTokens {
+	return AuthTokens{
+		AccessToken:  tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenLifetime.Seconds()),
+	}
+}
+
+func setAuthCookies(c *gin.Context, tokenString string, refreshToken string) {
+	cookie := config.Get().Cookie
+
+	c.SetSameSite(cookie.SameSiteMode())
+	c.SetCookie(auth.AccessCookie, tokenString, int(auth.AccessTokenLifetime.Seconds()), "/", cookie.Domain, cookie.Secure, cookie.HTTPOnly)
+	c.SetCookie(auth.RefreshCookie, refreshToken, int(auth.RefreshTokenLifetime.Seconds()), "/", cookie.Domain, cookie.Secure, true)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	cookie := config.Get().Cookie
+
+	c.SetSameSite(cookie.SameSiteMode())
+	c.SetCookie(auth.AccessCookie, "", -1, "/", cookie.Domain, cookie.Secure, cookie.HTTPOnly)
+	c.SetCookie(auth.RefreshCookie, "", -1, "/", cookie.Domain, cookie.Secure, true)
+}
+
+// refreshTokenFromRequest reads the refresh token from the JSON body, falling
+// back to the refresh cookie.
+func refreshTokenFromRequest(c *gin.Context) string {
+	type RefreshInput struct {
+		RefreshToken string
+	}
+
+	var input RefreshInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err == nil && input.RefreshToken != "" {
+			return input.RefreshToken
+		}
+	}
+
+	refreshToken, _ := c.Cookie(auth.RefreshCookie)
+	return refreshToken
+}
+
+func RefreshHandler(c *gin.Context) {
+	refreshToken := refreshTokenFromRequest(c)
+	if refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "No refresh token found"})
+		return
+	}
+
+	session, newRefreshToken, err := auth.RotateSession(refreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		clearAuthCookies(c)
+		if errors.Is(err, auth.ErrSessionRevoked) || errors.Is(err, auth.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired, please log in again"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	tokenString, err := auth.NewToken(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign token"})
+		return
+	}
+
+	setAuthCookies(c, tokenString, newRefreshToken)
+	c.JSON(http.StatusOK, newAuthTokens(tokenString, newRefreshToken))
+}
+
+func ListSessions(c *gin.Context) {
+	type SessionInfo struct {
+		ID         uint
+		UserAgent  string
+		IPAddress  string
+		CreatedAt  time.Time
+		LastUsedAt time.Time
+		ExpiresAt  time.Time
+		Current    bool
+	}
+
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	var sessions []model.Session
+	if err := db.Where("principal_id = ? AND principal_type = ? AND revoked_at IS NULL AND expires_at > ?", principal.ID, principal.Type, time.Now()).
+		Order("last_used_at desc").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	result := []SessionInfo{}
+	for _, session := range sessions {
+		result = append(result, SessionInfo{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == principal.SessionID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
+
+func RevokeSession(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	var session model.Session
+	if err := db.First(&session, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if session.PrincipalID != principal.ID || session.PrincipalType != principal.Type {
+		forbidden(c)
+		return
+	}
+
+	if err := auth.RevokeSession(session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	if session.ID == principal.SessionID {
+		clearAuthCookies(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+func RevokeOtherSessions(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	result := db.Model(&model.Session{}).
+		Where("principal_id = ? AND principal_type = ? AND id <> ? AND revoked_at IS NULL", principal.ID, principal.Type, principal.SessionID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked", "revoked": result.RowsAffected})
+}
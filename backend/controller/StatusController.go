@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"main/auth"
+	"main/database"
+	"main/dto"
+	"main/escrow"
+	"main/lifecycle"
+	model "main/models"
+	"main/settlement"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type UpdateStatusInput struct {
+	TransactionID uint   `json:"transactionId" binding:"required"`
+	NewStatus     string `json:"newStatus" binding:"required"`
+	Note          string `json:"note"`
+}
+
+// updateStatus moves one of the tailor's transactions along its lifecycle.
+// Only changes without money attached go through here; finishing and
+// cancelling have their own endpoints.
+func updateStatus(c *gin.Context) {
+	db := database.GetInstance()
+
+	var input UpdateStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var transaction model.Transaction
+	if err := db.First(&transaction, input.TransactionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	if !authorizeTailor(c, transaction.TailorID) {
+		return
+	}
+
+	status, err := lifecycle.Normalize(input.NewStatus)
+	// Cancelling refunds the buyer and finishing pays the tailor, which
+	// only their own endpoints do.
+	switch status {
+	case model.StatusCancelled:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cancel the transaction with the cancel endpoint, giving the buyer a reason"})
+		return
+	case model.StatusFinished:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A transaction is finished when the buyer confirms they received it"})
+		return
+	}
+	if err == nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			_, err := lifecycle.Apply(tx, transaction.ID, status, byPrincipal(c), input.Note)
+			return err
+		})
+	}
+	if respondStatusError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Status updated successfully", "status": status})
+}
+
+type DisputeInput struct {
+	TransactionID uint   `json:"transactionId" binding:"required"`
+	Reason        string `json:"reason"`
+}
+
+// DisputeTransaction lets the buyer report a problem with a shipped order
+// or request. The payment stays in escrow until an admin resolves it.
+func DisputeTransaction(c *gin.Context) {
+	db := database.GetInstance()
+
+	var input DisputeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var transaction model.Transaction
+	if err := db.First(&transaction, input.TransactionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	if !authorizeUser(c, transaction.UserID) {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := lifecycle.Apply(tx, transaction.ID, model.StatusDisputed, byPrincipal(c), input.Reason)
+		return err
+	})
+	if respondStatusError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dispute opened, an admin will review it"})
+}
+
+type ResolveDisputeInput struct {
+	Outcome string `json:"outcome" binding:"required,oneof=release refund"`
+	Note    string `json:"note"`
+}
+
+// ResolveDispute settles a disputed transaction, either releasing the
+// payment to the tailor or refunding the buyer.
+func ResolveDispute(c *gin.Context) {
+	db := database.GetInstance()
+
+	var input ResolveDisputeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var transaction model.Transaction
+	if err := db.First(&transaction, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	if transaction.Status != model.StatusDisputed {
+		c.JSON(http.StatusConflict, gin.H{"error": "The transaction is not disputed"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if input.Outcome == "release" {
+			return settlement.Finish(tx, transaction.ID, byPrincipal(c), input.Note, 0)
+		}
+		return settlement.Cancel(tx, transaction.ID, byPrincipal(c), input.Note)
+	})
+	if respondStatusError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dispute resolved"})
+}
+
+// GetTimeline shows the buyer, tailor or an admin how a transaction has
+// progressed: each milestone on its way to being finished, and every status
+// change with who made it.
+func GetTimeline(c *gin.Context) {
+	db := database.GetInstance()
+
+	var transaction model.Transaction
+	if err := db.Preload("Requests").First(&transaction, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	principal, _ := auth.GetPrincipal(c)
+	if !auth.Owns(c, auth.TypeUser, transaction.UserID) && !auth.Owns(c, auth.TypeTailor, transaction.TailorID) && principal.Type != auth.TypeAdmin {
+		forbidden(c)
+		return
+	}
+
+	var changes []model.StatusChange
+	if err := db.Where("transaction_id = ?", transaction.ID).Order("created_at, id").Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch timeline"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactionId": transaction.ID,
+		"status":        transaction.Status,
+		"steps":         timelineSteps(transaction, changes),
+		"history":       dto.NewStatusChanges(changes),
+	})
+}
+
+// timelineSteps lays out the milestones of a transaction. Custom requests
+// are made to order, so they have an In Progress step ready-made products
+// skip. A transaction that was cancelled or disputed ends on that step.
+func timelineSteps(transaction model.Transaction, changes []model.StatusChange) []dto.TimelineStep {
+	path := []string{model.StatusPendingPayment, model.StatusPaid, model.StatusAccepted}
+	if len(transaction.Requests) > 0 {
+		path = append(path, model.StatusInProgress)
+	}
+	path = append(path, model.StatusShipped, model.StatusDelivered, model.StatusFinished)
+
+	reachedAt := map[string]*time.Time{}
+	for i := range changes {
+		if _, ok := reachedAt[changes[i].ToStatus]; !ok {
+			reachedAt[changes[i].ToStatus] = &changes[i].CreatedAt
+		}
+	}
+
+	// Steps before the current status were passed even if history doesn't
+	// show them, since it starts when it was introduced.
+	current := -1
+	for i, status := range path {
+		if status == transaction.Status {
+			current = i
+		}
+	}
+
+	steps := make([]dto.TimelineStep, 0, len(path)+1)
+	for i, status := range path {
+		at, recorded := reachedAt[status]
+		steps = append(steps, dto.TimelineStep{Status: status, Reached: recorded || i <= current, At: at})
+	}
+	if transaction.Status == model.StatusCancelled || transaction.Status == model.StatusDisputed {
+		steps = append(steps, dto.TimelineStep{Status: transaction.Status, Reached: true, At: reachedAt[transaction.Status]})
+	}
+	return steps
+}
+
+// byPrincipal credits a status change to the signed-in principal.
+func byPrincipal(c *gin.Context) lifecycle.By {
+	principal, _ := auth.GetPrincipal(c)
+	by := lifecycle.By{Actor: lifecycle.ActorUser, ID: principal.ID}
+	switch principal.Type {
+	case auth.TypeTailor:
+		by.Actor = lifecycle.ActorTailor
+	case auth.TypeAdmin:
+		by.Actor = lifecycle.ActorAdmin
+	}
+	return by
+}
+
+// respondStatusError answers with the error a status change failed with,
+// and reports whether there was one.
+func respondStatusError(c *gin.Context, err error) bool {
+	var transition *lifecycle.TransitionError
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &transition):
+		c.JSON(http.StatusConflict, gin.H{"error": transitionMessage(transition)})
+	case errors.Is(err, lifecycle.ErrUnknownStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown status, expected one of: " + strings.Join(lifecycle.Statuses, ", ")})
+	case errors.Is(err, lifecycle.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	case errors.Is(err, escrow.ErrNoHold):
+		c.JSON(http.StatusConflict, gin.H{"error": "No payment is held in escrow for this transaction, please contact support"})
+	default:
+		log.Printf("Failed to update transaction status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+	}
+	return true
+}
+
+func transitionMessage(err *lifecycle.TransitionError) string {
+	message := fmt.Sprintf("A %s can't move a transaction from %s to %s", err.Actor, err.From, err.To)
+	if next := lifecycle.Next(err.From, err.Actor); len(next) > 0 {
+		return message + ". From " + err.From + " it can only move to " + strings.Join(next, " or ")
+	}
+	return message
+}
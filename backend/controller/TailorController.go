@@ -2,50 +2,38 @@ package controller
 
 import (
 	"fmt"
+	"main/auth"
+	"main/config"
 	"main/database"
+	"main/dto"
 	model "main/models"
+	"main/payout"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func GetAllTailor(c *gin.Context) {
-	type Speciality struct {
-		Category string
-		Price    int
-	}
-
 	type GetTailor struct {
-		ID      int
+		ID      uint
 		Name    string
 		Email   string
 		Address string
 		ImgUrl  string
 		Rating  float32
-		Money	int
-	}
-
-	type GetTailorFinal struct {
-		ID         int
-		Name       string
-		Email      string
-		Address    string
-		ImgUrl     string
-		Rating     float32
-		Money	   int
-		Speciality []Speciality
 	}
 
 	db := database.GetInstance()
 
 	var tailors []GetTailor
-	var tailorFinal []GetTailorFinal
+	var tailorFinal []dto.Tailor
 	query := c.Query("query")
 	speciality := c.Query("speciality")
 
-	sql := "SELECT tailors.id, tailors.name, tailors.email, tailors.address, tailors.img_url, round(avg(tailor_ratings.rating), 1) as rating, tailors.money " +
+	sql := "SELECT tailors.id, tailors.name, tailors.email, tailors.address, tailors.img_url, round(avg(tailor_ratings.rating), 1) as rating " +
 		"FROM tailors " +
 		"LEFT JOIN tailor_ratings ON tailor_ratings.tailor_id = tailors.id " +
 		"LEFT JOIN tailor_prices ON tailor_prices.tailor_id = tailors.id " +
@@ -79,21 +67,20 @@ func GetAllTailor(c *gin.Context) {
 	}
 
 	for _, tailor := range tailors {
-		var specialities []Speciality
+		var specialities []dto.Speciality
 		sql = "SELECT outfits.category, tailor_prices.price " +
 			"FROM tailor_prices " +
 			"JOIN outfits ON outfits.id = tailor_prices.outfit_id " +
 			"WHERE tailor_prices.tailor_id = ?"
 		db.Raw(sql, tailor.ID).Scan(&specialities)
 
-		tailorFinal = append(tailorFinal, GetTailorFinal{
+		tailorFinal = append(tailorFinal, dto.Tailor{
 			ID:         tailor.ID,
 			Name:       tailor.Name,
 			Address:    tailor.Address,
 			Email:      tailor.Email,
 			ImgUrl:     tailor.ImgUrl,
 			Rating:     tailor.Rating,
-			Money: 		tailor.Money,
 			Speciality: specialities,
 		})
 	}
@@ -108,18 +95,32 @@ func GetTailor(c *gin.Context){
 
 	tailor := model.GetTailor(uint(tid))
 
-	c.JSON(http.StatusOK, tailor)
+	if auth.Owns(c, auth.TypeTailor, uint(tid)) {
+		c.JSON(http.StatusOK, dto.NewTailorAccount(tailor))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewTailor(tailor))
 	
 }
 
 type WithdrawAmount struct {
 	Amount int 
+	BankAccountID uint
+	TotpCode string
 }
 
+// WithdrawalHandler files a withdrawal request. The amount is held from the
+// tailor's balance until an admin approves and pays it out, or rejects it.
+// BankAccountID may be left out when the tailor has only one bank account.
 func WithdrawalHandler(c *gin.Context) {
 	db := database.GetInstance()
    
 	tailorID := c.Param("id")
+
+	if !authorizeTailorParam(c, tailorID) {
+		return
+	}
    
 	var input WithdrawAmount
 	var tailor model.Tailor
@@ -134,13 +135,65 @@ func WithdrawalHandler(c *gin.Context) {
 	 	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 	 	return
 	}
+
+	if input.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+		return
+	}
+
+	if input.BankAccountID == 0 {
+		var bankAccounts []model.BankAccount
+		db.Where("tailor_id = ?", tailor.ID).Limit(2).Find(&bankAccounts)
+		if len(bankAccounts) != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Choose the bank account to withdraw to, or register one first"})
+			return
+		}
+		input.BankAccountID = bankAccounts[0].ID
+	}
+
+	if !verifyWithdrawalTOTP(c, tailor, input) {
+		return
+	}
    
-	tailor.Money -= input.Amount
-   
-	if err := db.Save(&tailor).Error; err != nil {
-	 	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update balance"})
-	 	return
+	var request model.WithdrawalRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		request, err = payout.Request(tx, tailor.ID, input.BankAccountID, input.Amount, withdrawalLimits())
+		return err
+	})
+	if respondPayoutError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"withdrawal": dto.NewWithdrawal(request), "tailor": dto.NewTailorAccount(model.GetTailor(tailor.ID))})
+}
+
+// verifyWithdrawalTOTP demands a fresh authenticator code for withdrawals
+// above the configured threshold. Recovery codes are deliberately not
+// accepted here.
+func verifyWithdrawalTOTP(c *gin.Context, tailor model.Tailor, input WithdrawAmount) bool {
+	threshold := config.Get().WithdrawalTOTPThreshold
+	if input.Amount <= threshold {
+		return true
+	}
+
+	db := database.GetInstance()
+	principal := auth.Principal{ID: tailor.ID, Type: auth.TypeTailor}
+
+	twoFactor, found := auth.GetTwoFactor(db, tailor.ID, auth.TypeTailor)
+	if !found || !twoFactor.IsEnabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Enable two-factor authentication to withdraw more than %d", threshold)})
+		return false
+	}
+
+	if input.TotpCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An authentication code is required to withdraw more than %d", threshold)})
+		return false
+	}
+
+	if twoFactorThrottled(c, principal) {
+		return false
 	}
 
-	c.JSON(http.StatusOK, gin.H{"tailor": tailor})
+	return respondTwoFactorError(c, principal, auth.VerifyTOTP(db, twoFactor, input.TotpCode))
 }
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"main/auth"
+	"main/database"
+	model "main/models"
+	"main/settlement"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CancelTransactionRequest struct {
+	TransactionID uint   `json:"transactionId" binding:"required"`
+	Reason        string `json:"reason"`
+}
+
+// CancelTransaction lets the buyer call off an order or request until the
+// tailor accepts it, and the tailor call it off until it ships, giving the
+// buyer a reason. A paid transaction is refunded to the buyer's wallet and
+// its products go back on sale.
+func CancelTransaction(c *gin.Context) {
+	db := database.GetInstance()
+
+	var input CancelTransactionRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var transaction model.Transaction
+	if err := db.First(&transaction, input.TransactionID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	principal, _ := auth.GetPrincipal(c)
+	if principal.Type == auth.TypeTailor {
+		if !authorizeTailor(c, transaction.TailorID) {
+			return
+		}
+		if strings.TrimSpace(input.Reason) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Please tell the buyer why the transaction is cancelled"})
+			return
+		}
+	} else if !authorizeUser(c, transaction.UserID) {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return settlement.Cancel(tx, transaction.ID, byPrincipal(c), input.Reason)
+	})
+	if respondStatusError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction cancelled"})
+}
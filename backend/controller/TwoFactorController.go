@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"main/auth"
+	"main/database"
+	model "main/models"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const totpIssuer = "TailorTech"
+
+type TwoFactorCodeInput struct {
+	Code string `binding:"required"`
+}
+
+func principalEmail(principal auth.Principal) string {
+	db := database.GetInstance()
+
+	var email string
+	switch principal.Type {
+	case auth.TypeTailor:
+		db.Model(&model.Tailor{}).Where("id = ?", principal.ID).Pluck("email", &email)
+	case auth.TypeAdmin:
+		db.Model(&model.Admin{}).Where("id = ?", principal.ID).Pluck("email", &email)
+	}
+	return email
+}
+
+// SetupTwoFactor starts TOTP enrolment. The returned URI is meant to be shown
+// as a QR code; 2FA only takes effect once EnableTwoFactor confirms a code.
+func SetupTwoFactor(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	twoFactor, found := auth.GetTwoFactor(db, principal.ID, principal.Type)
+	if found && twoFactor.IsEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	twoFactor.PrincipalID = principal.ID
+	twoFactor.PrincipalType = principal.Type
+	twoFactor.Secret = secret
+	twoFactor.LastStep = 0
+	if err := db.Save(&twoFactor).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Secret": secret,
+		"URI":    auth.TOTPURI(totpIssuer, principalEmail(principal), secret),
+	})
+}
+
+func EnableTwoFactor(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	if twoFactorThrottled(c, principal) {
+		return
+	}
+
+	var input TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	twoFactor, found := auth.GetTwoFactor(db, principal.ID, principal.Type)
+	if !found {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+	if twoFactor.IsEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := auth.VerifyTOTP(tx, twoFactor, input.Code); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&twoFactor).Update("enabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = auth.NewRecoveryCodes(tx, principal.ID, principal.Type)
+		return err
+	})
+
+	if !respondTwoFactorError(c, principal, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Two-factor authentication enabled. Store these recovery codes somewhere safe.",
+		"RecoveryCodes": codes,
+	})
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	if twoFactorThrottled(c, principal) {
+		return
+	}
+
+	var input TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := auth.VerifySecondFactor(tx, principal.ID, principal.Type, input.Code); err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("principal_id = ? AND principal_type = ?", principal.ID, principal.Type).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("principal_id = ? AND principal_type = ?", principal.ID, principal.Type).Delete(&model.TwoFactor{}).Error
+	})
+
+	if !respondTwoFactorError(c, principal, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	if twoFactorThrottled(c, principal) {
+		return
+	}
+
+	var input TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	twoFactor, found := auth.GetTwoFactor(db, principal.ID, principal.Type)
+	if !found || !twoFactor.IsEnabled() {
+		respondTwoFactorError(c, principal, auth.ErrTwoFactorNotEnabled)
+		return
+	}
+
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := auth.VerifyTOTP(tx, twoFactor, input.Code); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = auth.NewRecoveryCodes(tx, principal.ID, principal.Type)
+		return err
+	})
+
+	if !respondTwoFactorError(c, principal, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"RecoveryCodes": codes})
+}
+
+// twoFactorThrottled answers 429 while the principal is locked out after
+// too many wrong codes.
+func twoFactorThrottled(c *gin.Context, principal auth.Principal) bool {
+	wait := auth.TwoFactorLockedFor(principal.Type, principal.ID)
+	if wait <= 0 {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, please try again later"})
+	return true
+}
+
+// respondTwoFactorError writes the error response for err and returns false,
+// or clears the principal's failure count and returns true when err is nil.
+// Wrong codes count towards the two-factor lockout.
+func respondTwoFactorError(c *gin.Context, principal auth.Principal, err error) bool {
+	switch {
+	case err == nil:
+		auth.UnlockLogin(auth.TwoFactorSubject(principal.Type, principal.ID))
+		return true
+	case errors.Is(err, auth.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+	case errors.Is(err, auth.ErrInvalidTwoFactor):
+		if err := auth.RecordTwoFactorFailure(principal.Type, principal.ID); err != nil {
+			log.Printf("Failed to record two-factor failure: %v", err)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+	default:
+		log.Printf("Two-factor verification failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify authentication code"})
+	}
+	return false
+}
@@ -1,11 +1,13 @@
 package controller
 
 import (
+	"fmt"
 	"log"
 	"main/database"
+	"main/dto"
+	"main/gateway"
 	models "main/models"
 	"net/http"
-	"regexp"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -16,35 +18,34 @@ func GetUser(c *gin.Context) {
 	db := database.GetInstance()
 	userID := c.Param("id")
 
-	var user models.User
-	if err := db.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !authorizeUserParam(c, userID) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
-}
-
-func GetAllUsers(c *gin.Context) {
-	db := database.GetInstance()
-
-	var users []models.User
-	if err := db.Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"users": users})
+	c.JSON(http.StatusOK, gin.H{"user": dto.NewUser(user)})
 }
 
 type TopUpInput struct {
-	Amount int `json:"amount"`
+	Amount        int    `json:"amount"`
+	PaymentMethod string `json:"paymentMethod"`
 }
-   
+
+// TopUpHandler starts a top-up with the payment provider. The balance only
+// changes once the provider confirms the payment through its webhook.
 func TopUpHandler(c *gin.Context) {
 	db := database.GetInstance()
    
 	userID := c.Param("id")
+
+	if !authorizeUserParam(c, userID) {
+		return
+	}
    
 	var input TopUpInput
 	var user models.User
@@ -54,19 +55,26 @@ func TopUpHandler(c *gin.Context) {
 	 	return
 	}
    
-	if err := db.First(&user, userID).Error; err != nil {
-	 	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-	 	return
+	if input.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+		return
+	}
+
+	if !gateway.IsProviderMethod(input.PaymentMethod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Payment method must be %s, %s or %s", gateway.MethodVirtualAccount, gateway.MethodEWallet, gateway.MethodQRIS)})
+		return
 	}
    
-	user.Money += input.Amount
-   
-	if err := db.Save(&user).Error; err != nil {
-	 	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update balance"})
+	if err := db.First(&user, userID).Error; err != nil {
+	 	c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 	 	return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	startTopUp(c, user, models.TopUp{
+		UserID: user.ID,
+		Amount: input.Amount,
+		Method: input.PaymentMethod,
+	})
 }
 
 func UpdateUser(c *gin.Context) {
@@ -90,6 +98,12 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if !authorizeUser(c, input.UserID) {
+		return
+	}
+
+	emailChanged := false
+
 	var user models.User
 	if db.Where("id = ?", input.UserID).First(&user).RowsAffected == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -97,14 +111,8 @@ func UpdateUser(c *gin.Context) {
 	}
 
 	if input.Name != "" {
-		if len(input.Name) < 5 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be more than 5 characters"})
-			return
-		}
-
-		regex := regexp.MustCompile(`^[a-zA-Z\s]+$`)
-		if !regex.MatchString(input.Name) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Name must not contain symbols or numbers"})
+		if msg := validateName(input.Name); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 			return
 		}
 		user.Name = input.Name
@@ -121,7 +129,11 @@ func UpdateUser(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "This email has been registered"})
 			return
 		}
-		user.Email = input.Email
+		if input.Email != user.Email {
+			user.Email = input.Email
+			user.EmailVerifiedAt = nil
+			emailChanged = true
+		}
 	}
 
 	if input.NewPassword != "" {
@@ -135,44 +147,20 @@ func UpdateUser(c *gin.Context) {
 			return
 		}
 
-		var passwordChecks []string
-
-		if len(input.NewPassword) < 8 {
-			passwordChecks = append(passwordChecks, "Password must be at least 8 characters long")
-		}
-
-		if !containsUppercase(input.NewPassword) {
-			passwordChecks = append(passwordChecks, "Password must contain at least one uppercase letter")
-		}
-
-		if !containsLowercase(input.NewPassword) {
-			passwordChecks = append(passwordChecks, "Password must contain at least one lowercase letter")
-		}
-
-		if !containsDigit(input.NewPassword) {
-			passwordChecks = append(passwordChecks, "Password must contain at least one digit")
-		}
-
-		if !containsSpecialChar(input.NewPassword) {
-			passwordChecks = append(passwordChecks, "Password must contain at least one special character")
-		}
-
-		if len(passwordChecks) > 0 {
+		if passwordChecks := validatePassword(input.NewPassword); len(passwordChecks) > 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": strings.Join(passwordChecks, ", ")})
 			return
 		}
 
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
-		if err != nil {
+		if err := user.SetPassword(input.NewPassword); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not hash password"})
 			return
 		}
-		user.Password = string(hashedPassword)
 	}
 
 	if input.PhoneNumber != "" {
-		if len(input.PhoneNumber) < 10 || len(input.PhoneNumber) > 13 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Phone number must be between 10 and 13 digits long"})
+		if msg := validatePhoneNumber(input.PhoneNumber); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 			return
 		}
 		user.PhoneNumber = input.PhoneNumber
@@ -186,11 +174,17 @@ func UpdateUser(c *gin.Context) {
 		user.Points = input.Points
 	}
 
-	if err := db.Save(&user).Error; err != nil {
+	if err := db.Omit("money").Save(&user).Error; err != nil {
 		log.Printf("Failed to update user: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully!", "user": user})
+	if emailChanged {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("Failed to send verification email: %v", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully!", "user": dto.NewUser(user)})
 }
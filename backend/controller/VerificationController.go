@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"main/auth"
+	"main/database"
+	"main/mail"
+	model "main/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const emailVerificationLifetime = time.Hour * 24
+
+func sendVerificationEmail(user model.User) error {
+	token, err := auth.IssueUserToken(user.ID, user.Email, model.TokenPurposeEmailVerification, emailVerificationLifetime)
+	if err != nil {
+		return err
+	}
+
+	return mail.Get().Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your TailorTech email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm this email address for your TailorTech account "+
+			"by opening the link below within %d hours:\n\n%s\n\n"+
+			"Or enter this code in the app: %s\n\n"+
+			"If you didn't create a TailorTech account, you can ignore this email.\n",
+			user.Name, int(emailVerificationLifetime.Hours()), appLink("/verify-email", token), token),
+	})
+}
+
+func VerifyEmail(c *gin.Context) {
+	db := database.GetInstance()
+
+	type VerifyEmailInput struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	var input VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		userToken, err := auth.ConsumeUserToken(tx, input.Token, model.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND email = ?", userToken.UserID, userToken.Email).
+			Update("email_verified_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return auth.ErrInvalidUserToken
+		}
+		return nil
+	})
+
+	if errors.Is(err, auth.ErrInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This verification link is invalid or has expired"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to verify email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Your email address has been verified"})
+}
+
+func ResendVerificationEmail(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	var user model.User
+	if err := db.First(&user, principal.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.IsEmailVerified() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Your email address is already verified"})
+		return
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "A new verification link has been sent to your email"})
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"main/auth"
+	"main/database"
+	"main/dto"
+	"main/ledger"
+	model "main/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// walletFor returns the wallet account of a user or tailor principal.
+func walletFor(principal auth.Principal) (ledger.Account, bool) {
+	switch principal.Type {
+	case auth.TypeUser:
+		return ledger.UserWallet(principal.ID), true
+	case auth.TypeTailor:
+		return ledger.TailorWallet(principal.ID), true
+	}
+	return ledger.Account{}, false
+}
+
+// GetWalletStatement lists the movements on the caller's wallet, newest
+// first.
+func GetWalletStatement(c *gin.Context) {
+	db := database.GetInstance()
+	principal, _ := auth.GetPrincipal(c)
+
+	account, ok := walletFor(principal)
+	if !ok {
+		forbidden(c)
+		return
+	}
+
+	var ledgerAccount model.LedgerAccount
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		ledgerAccount, err = ledger.GetAccount(tx, account)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load wallet"})
+		return
+	}
+
+	query := db.Table("postings").
+		Select("postings.id, postings.created_at, journal_entries.kind, journal_entries.description, journal_entries.transaction_id, postings.amount, postings.balance_after").
+		Joins("JOIN journal_entries ON journal_entries.id = postings.journal_entry_id").
+		Where("postings.account_id = ?", ledgerAccount.ID)
+
+	lines := []dto.StatementLine{}
+	if err := paginate(c, query).Order("postings.id desc").Scan(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch statement"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.Statement{Balance: ledgerAccount.Balance, Lines: lines})
+}
+
+// ReconcileLedger reports ledger balances that don't match their postings or
+// the cached wallet balances, and journal entries that don't sum to zero.
+func ReconcileLedger(c *gin.Context) {
+	db := database.GetInstance()
+
+	discrepancies, unbalanced, err := ledger.Reconcile(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"consistent":        len(discrepancies) == 0 && len(unbalanced) == 0,
+		"discrepancies":     discrepancies,
+		"unbalancedEntries": unbalanced,
+	})
+}
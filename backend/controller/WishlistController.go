@@ -2,6 +2,7 @@ package controller
 
 import (
     "main/database"
+    "main/dto"
     models "main/models"
     "net/http"
     "strconv"
@@ -31,6 +32,10 @@ func AddToWishlist(c *gin.Context) {
         return
     }
 
+    if !authorizeUser(c, uint(input.UserID)) {
+        return
+    }
+
     var user models.User
     if err := db.Where("id = ?", input.UserID).First(&user).Error; err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
@@ -46,15 +51,6 @@ func AddToWishlist(c *gin.Context) {
 }
 
 func GetWishlist(c *gin.Context) {
-    type GetProduct struct {
-        ID      int
-        Product string
-        Tailor  string
-        Desc    string
-        Price   int
-        ImgUrl  string
-        Size    string
-    }
     db := database.GetInstance()
 
     userIDStr := c.Param("userID")
@@ -63,13 +59,17 @@ func GetWishlist(c *gin.Context) {
         return
     }
 
+    if !authorizeUserParam(c, userIDStr) {
+        return
+    }
+
     userID, err := strconv.Atoi(userIDStr)
     if err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid userID parameter"})
         return
     }
 
-    var products []GetProduct
+    var products []dto.ProductListing
     sql := "SELECT products.id, products.name as product, tailors.name as tailor, `desc`, products.price, products.img_url, products.size " +
         "FROM products " +
         "LEFT JOIN tailors ON products.tailor_id = tailors.id " +
@@ -99,6 +99,10 @@ func RemoveFromWishlist(c *gin.Context) {
         return
     }
 
+    if !authorizeUser(c, uint(input.UserID)) {
+        return
+    }
+
     if err := db.Where("user_id = ? AND product_id = ?", input.UserID, input.ProductID).Delete(&Wishlist{}).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
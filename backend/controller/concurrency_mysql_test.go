@@ -0,0 +1,291 @@
+//go:build mysql
+
+package controller
+
+// These tests race the money handlers against each other on MySQL, whose
+// row locks they depend on; SQLite has none. They need a throwaway database,
+// which they migrate and write to:
+//
+//	TAILORTECH_TEST_DATABASE_DSN='root:secret@tcp(localhost:3306)/tailortech_test?parseTime=true' \
+//	    go test -tags mysql -race ./controller
+
+import (
+	"fmt"
+	"main/auth"
+	"main/checkout"
+	"main/config"
+	"main/gateway"
+	"main/ledger"
+	model "main/models"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func setUpMySQL(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TAILORTECH_TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TAILORTECH_TEST_DATABASE_DSN is not set")
+	}
+	return setUp(t, dsn, mysql.Open(dsn))
+}
+
+// TestConcurrentWalletHandlers tops up a buyer's wallet, pays for orders
+// from it and has a tailor withdraw, all at once, with every webhook
+// delivered twice. The balances must come out as if it had all happened one
+// request at a time.
+func TestConcurrentWalletHandlers(t *testing.T) {
+	db := setUpMySQL(t)
+	cfg := config.Get()
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	const (
+		opening     = 100
+		topUps      = 20
+		topUpAmount = 10
+		orders      = 20
+		orderPrice  = 30
+
+		tailorOpening = 500
+		withdrawals   = 10
+		withdrawal    = 100
+	)
+
+	now := time.Now()
+	user := model.User{Name: "Buyer", Email: "buyer-" + run + "@example.com", Money: opening, EmailVerifiedAt: &now}
+	tailor := model.Tailor{Name: "Tailor", Email: "tailor-" + run + "@example.com", Money: tailorOpening}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&tailor).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.BankAccount{TailorID: tailor.ID, BankCode: "BCA", AccountNumber: "1234567890", AccountHolder: "Tailor"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	buyer := auth.Principal{Type: auth.TypeUser, ID: user.ID}
+	seller := auth.Principal{Type: auth.TypeTailor, ID: tailor.ID}
+	userParam := gin.Params{{Key: "id", Value: strconv.Itoa(int(user.ID))}}
+	tailorParam := gin.Params{{Key: "id", Value: strconv.Itoa(int(tailor.ID))}}
+
+	for i := 0; i < topUps; i++ {
+		body := []byte(fmt.Sprintf(`{"amount":%d,"paymentMethod":"%s"}`, topUpAmount, gateway.MethodVirtualAccount))
+		if w := serve(TopUpHandler, buyer, userParam, nil, body); w.Code != http.StatusCreated {
+			t.Fatalf("TopUpHandler = %d %s", w.Code, w.Body)
+		}
+	}
+	var pending []model.TopUp
+	db.Where("user_id = ?", user.ID).Find(&pending)
+	if len(pending) != topUps {
+		t.Fatalf("%d top-ups started, want %d", len(pending), topUps)
+	}
+
+	payments := make([][]byte, orders)
+	for i := range payments {
+		quote := checkout.Quote{ID: run + "-" + strconv.Itoa(i), Kind: checkout.KindOrder, UserID: user.ID, Total: orderPrice, ExpiresAt: time.Now().Add(checkout.TTL)}
+		transaction := model.Transaction{TransactionDate: time.Now(), UserID: user.ID, TailorID: tailor.ID, Status: model.StatusPendingPayment, TotalPrice: orderPrice, QuoteID: quote.ID}
+		if err := db.Create(&transaction).Error; err != nil {
+			t.Fatal(err)
+		}
+		token, err := checkout.Sign(cfg.Checkout.QuoteSecret, quote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payments[i] = []byte(fmt.Sprintf(`{"userId":%d,"transactionIds":[%d],"quoteToken":%q}`, user.ID, transaction.ID, token))
+	}
+
+	mock := gateway.Get().(*gateway.MockProvider)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[string]map[int]int{}
+	record := func(kind string, code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if codes[kind] == nil {
+			codes[kind] = map[int]int{}
+		}
+		codes[kind][code]++
+	}
+
+	for _, topUp := range pending {
+		header, body, err := mock.Callback(gateway.Event{
+			ProviderReference: topUp.ProviderReference,
+			Reference:         topUpReference(topUp),
+			Status:            gateway.EventPaid,
+			Amount:            topUp.Amount,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Providers retry webhooks, so each one arrives twice.
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := serve(GatewayWebhook, auth.Principal{}, gin.Params{{Key: "provider", Value: mock.Name()}}, header, body)
+				record("webhook", w.Code)
+			}()
+		}
+	}
+	for _, body := range payments {
+		wg.Add(1)
+		go func(body []byte) {
+			defer wg.Done()
+			w := serve(ProcessPayment, buyer, nil, nil, body)
+			record("payment", w.Code)
+		}(body)
+	}
+	for i := 0; i < withdrawals; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serve(WithdrawalHandler, seller, tailorParam, nil, []byte(fmt.Sprintf(`{"amount":%d}`, withdrawal)))
+			record("withdrawal", w.Code)
+		}()
+	}
+	wg.Wait()
+
+	if codes["webhook"][http.StatusOK] != 2*topUps {
+		t.Errorf("webhook responses = %v, want all %d OK", codes["webhook"], 2*topUps)
+	}
+	paid := codes["payment"][http.StatusOK]
+	if paid+codes["payment"][http.StatusBadRequest] != orders {
+		t.Errorf("payment responses = %v, want only OK or insufficient balance", codes["payment"])
+	}
+	withdrawn := codes["withdrawal"][http.StatusCreated]
+	if withdrawn != tailorOpening/withdrawal || withdrawn+codes["withdrawal"][http.StatusBadRequest] != withdrawals {
+		t.Errorf("withdrawal responses = %v, want %d created and the rest refused", codes["withdrawal"], tailorOpening/withdrawal)
+	}
+
+	var credited int64
+	db.Model(&model.TopUp{}).Where("user_id = ? AND status = ?", user.ID, model.TopUpPaid).Count(&credited)
+	if credited != topUps {
+		t.Errorf("%d top-ups paid, want %d", credited, topUps)
+	}
+	var held int64
+	db.Model(&model.EscrowHold{}).Where("user_id = ?", user.ID).Count(&held)
+	if int(held) != paid {
+		t.Errorf("%d payments held in escrow, want %d", held, paid)
+	}
+
+	db.First(&user, user.ID)
+	db.First(&tailor, tailor.ID)
+	if want := opening + topUps*topUpAmount - paid*orderPrice; user.Money != want {
+		t.Errorf("User.Money = %d, want %d", user.Money, want)
+	}
+	if want := tailorOpening - withdrawn*withdrawal; tailor.Money != want {
+		t.Errorf("Tailor.Money = %d, want %d", tailor.Money, want)
+	}
+
+	for _, wallet := range []ledger.Account{ledger.UserWallet(user.ID), ledger.TailorWallet(tailor.ID)} {
+		account, err := ledger.GetAccount(db, wallet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var negative int64
+		db.Model(&model.Posting{}).Where("account_id = ? AND balance_after < 0", account.ID).Count(&negative)
+		if negative != 0 {
+			t.Errorf("%s %d went negative %d times", wallet.Kind, wallet.OwnerID, negative)
+		}
+	}
+
+	discrepancies, unbalanced, err := ledger.Reconcile(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 || len(unbalanced) != 0 {
+		t.Errorf("Reconcile() = %+v, %v, want no discrepancies", discrepancies, unbalanced)
+	}
+}
+
+// TestConcurrentQuotePlacement submits the same signed quote many times at
+// once. Only one submission may place it.
+func TestConcurrentQuotePlacement(t *testing.T) {
+	db := setUpMySQL(t)
+	cfg := config.Get()
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	now := time.Now()
+	user := model.User{Name: "Buyer", Email: "quote-" + run + "@example.com", EmailVerifiedAt: &now}
+	tailor := model.Tailor{Name: "Tailor", Email: "quote-tailor-" + run + "@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&tailor).Error; err != nil {
+		t.Fatal(err)
+	}
+	product := model.Product{Name: "Shirt", Price: 50, TailorID: tailor.ID, IsActive: true}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		quote   checkout.Quote
+		body    string
+	}{
+		{
+			name:    "request",
+			handler: CreateUserRequest,
+			quote:   checkout.Quote{Kind: checkout.KindRequest, Lines: []checkout.Line{{TailorID: tailor.ID, OutfitID: 1, Price: 40}}, Total: 50},
+			body:    `{"UserID":%d,"Name":"Kebaya","Desc":"Fitted","QuoteToken":%q}`,
+		},
+		{
+			name:    "order",
+			handler: CreateProductOrder,
+			quote:   checkout.Quote{Kind: checkout.KindOrder, Lines: []checkout.Line{{TailorID: tailor.ID, ProductID: product.ID, Price: 50}}, Total: 60},
+			body:    `{"UserID":%d,"Name":"Buyer","QuoteToken":%q}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.quote.ID = run + "-" + tt.name
+			tt.quote.UserID = user.ID
+			tt.quote.ExpiresAt = time.Now().Add(checkout.TTL)
+			token, err := checkout.Sign(cfg.Checkout.QuoteSecret, tt.quote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := []byte(fmt.Sprintf(tt.body, user.ID, token))
+
+			const attempts = 10
+			var wg sync.WaitGroup
+			created := make(chan int, attempts)
+			for i := 0; i < attempts; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					created <- serve(tt.handler, auth.Principal{Type: auth.TypeUser, ID: user.ID}, nil, nil, body).Code
+				}()
+			}
+			wg.Wait()
+			close(created)
+
+			codes := map[int]int{}
+			for code := range created {
+				codes[code]++
+			}
+			if codes[http.StatusCreated] != 1 || codes[http.StatusConflict] != attempts-1 {
+				t.Errorf("responses = %v, want one created and %d conflicts", codes, attempts-1)
+			}
+
+			var placed int64
+			db.Model(&model.Transaction{}).Where("quote_id = ?", tt.quote.ID).Count(&placed)
+			if placed != 1 {
+				t.Errorf("%d transactions placed with the quote, want 1", placed)
+			}
+		})
+	}
+}
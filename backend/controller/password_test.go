@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"main/auth"
+	"main/config"
+	"main/mail"
+	model "main/models"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestForgotPasswordDoesNotRevealAccounts checks that a registered address
+// gets the same answer as an unknown one, even when the email can't be sent.
+func TestForgotPasswordDoesNotRevealAccounts(t *testing.T) {
+	db := setUpSQLite(t)
+
+	user := model.User{Name: "Buyer", Email: "buyer@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// An outbox that is a file can't be written to.
+	cfg := config.Get().Mail
+	cfg.OutboxDir = filepath.Join(t.TempDir(), "outbox")
+	if err := os.WriteFile(cfg.OutboxDir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := mail.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := serve(ForgotPassword, auth.Principal{}, nil, nil, []byte(`{"email":"nobody@example.com"}`))
+	registered := serve(ForgotPassword, auth.Principal{}, nil, nil, []byte(`{"email":"buyer@example.com"}`))
+	if unknown.Code != http.StatusOK || registered.Code != unknown.Code || registered.Body.String() != unknown.Body.String() {
+		t.Errorf("ForgotPassword = %d %s for a registered address and %d %s for an unknown one, want the same",
+			registered.Code, registered.Body, unknown.Code, unknown.Body)
+	}
+}
@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/auth"
+	"main/checkout"
+	"main/config"
+	model "main/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// placeSplitOrder checks out two tailors' products, 40 and 60, with a 10
+// promo discount and 10 shipping, which come to 100 split 40 and 60. It
+// returns the signed quote and both transactions.
+func placeSplitOrder(t *testing.T, db *gorm.DB, user model.User) (string, []model.Transaction) {
+	t.Helper()
+
+	var products []model.Product
+	for i, price := range []int{40, 60} {
+		tailor := model.Tailor{Name: "Tailor", Email: fmt.Sprintf("tailor-%d@example.com", i)}
+		if err := db.Create(&tailor).Error; err != nil {
+			t.Fatal(err)
+		}
+		product := model.Product{Name: "Shirt", Price: price, TailorID: tailor.ID, IsActive: true}
+		if err := db.Create(&product).Error; err != nil {
+			t.Fatal(err)
+		}
+		products = append(products, product)
+	}
+
+	quote, err := checkout.QuoteOrder(db, user.ID, []uint{products[0].ID, products[1].ID}, "SAVE10", config.Get().Checkout.ShippingFee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := checkout.Sign(config.Get().Checkout.QuoteSecret, quote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buyer := auth.Principal{Type: auth.TypeUser, ID: user.ID}
+	w := serve(CreateProductOrder, buyer, nil, nil, []byte(fmt.Sprintf(`{"UserID":%d,"Name":"Buyer","QuoteToken":%q}`, user.ID, token)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateProductOrder = %d %s", w.Code, w.Body)
+	}
+	var placed struct{ TransactionIDs []uint }
+	if err := json.Unmarshal(w.Body.Bytes(), &placed); err != nil {
+		t.Fatal(err)
+	}
+
+	var transactions []model.Transaction
+	db.Order("total_price").Find(&transactions, placed.TransactionIDs)
+	if len(transactions) != 2 || transactions[0].TotalPrice != 40 || transactions[1].TotalPrice != 60 {
+		t.Fatalf("placed %+v, want transactions of 40 and 60", transactions)
+	}
+	for _, transaction := range transactions {
+		if transaction.Subtotal != transaction.TotalPrice || transaction.TailorTier != model.TailorTierStandard {
+			t.Errorf("placed with subtotal %d and tier %q, want %d and %q", transaction.Subtotal, transaction.TailorTier, transaction.TotalPrice, model.TailorTierStandard)
+		}
+	}
+	return token, transactions
+}
+
+func TestPayPartOfAQuote(t *testing.T) {
+	tests := []struct {
+		name string
+		// pay is given both transactions and pays for some of them.
+		pay       func(t *testing.T, pay func(ids ...uint) int, cancel func(id uint) int, transactions []model.Transaction)
+		userMoney int
+		promos    int
+	}{
+		{
+			name: "the other was cancelled",
+			pay: func(t *testing.T, pay func(ids ...uint) int, cancel func(id uint) int, transactions []model.Transaction) {
+				if code := cancel(transactions[1].ID); code != http.StatusOK {
+					t.Fatalf("CancelTransaction = %d", code)
+				}
+				if code := pay(transactions[0].ID); code != http.StatusOK {
+					t.Fatalf("ProcessPayment = %d", code)
+				}
+				if code := pay(transactions[1].ID); code != http.StatusConflict {
+					t.Errorf("ProcessPayment for the cancelled one = %d, want %d", code, http.StatusConflict)
+				}
+			},
+			userMoney: 200 - 40,
+			promos:    1,
+		},
+		{
+			name: "paid one at a time",
+			pay: func(t *testing.T, pay func(ids ...uint) int, cancel func(id uint) int, transactions []model.Transaction) {
+				for _, transaction := range transactions {
+					if code := pay(transaction.ID); code != http.StatusOK {
+						t.Fatalf("ProcessPayment for %d = %d", transaction.TotalPrice, code)
+					}
+				}
+			},
+			userMoney: 200 - 100,
+			promos:    1,
+		},
+		{
+			name: "paid together",
+			pay: func(t *testing.T, pay func(ids ...uint) int, cancel func(id uint) int, transactions []model.Transaction) {
+				if code := pay(transactions[0].ID, transactions[1].ID); code != http.StatusOK {
+					t.Fatalf("ProcessPayment = %d", code)
+				}
+			},
+			userMoney: 200 - 100,
+			promos:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setUpSQLite(t)
+
+			now := time.Now()
+			user := model.User{Name: "Buyer", Email: "buyer@example.com", Money: 200, EmailVerifiedAt: &now}
+			if err := db.Create(&user).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Create(&model.Promo{PromoCode: "SAVE10", Discount: 10}).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Create(&model.UserPromo{PromoCode: "SAVE10", UserID: user.ID, Quantity: 2}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			token, transactions := placeSplitOrder(t, db, user)
+			buyer := auth.Principal{Type: auth.TypeUser, ID: user.ID}
+			pay := func(ids ...uint) int {
+				body, _ := json.Marshal(PaymentRequest{UserID: user.ID, TransactionIDs: ids, QuoteToken: token})
+				return serve(ProcessPayment, buyer, nil, nil, body).Code
+			}
+			cancel := func(id uint) int {
+				return serve(CancelTransaction, buyer, nil, nil, []byte(fmt.Sprintf(`{"transactionId":%d}`, id))).Code
+			}
+			tt.pay(t, pay, cancel, transactions)
+
+			var userPromo model.UserPromo
+			db.Where("promo_code = ? AND user_id = ?", "SAVE10", user.ID).Limit(1).Find(&userPromo)
+			db.First(&user, user.ID)
+			if user.Money != tt.userMoney || userPromo.Quantity != tt.promos {
+				t.Errorf("User.Money, promos left = %d, %d, want %d, %d", user.Money, userPromo.Quantity, tt.userMoney, tt.promos)
+			}
+		})
+	}
+}
+
+func TestPayRefusesATransactionOffItsShare(t *testing.T) {
+	db := setUpSQLite(t)
+
+	now := time.Now()
+	user := model.User{Name: "Buyer", Email: "buyer@example.com", Money: 200, EmailVerifiedAt: &now}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.Promo{PromoCode: "SAVE10", Discount: 10}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.UserPromo{PromoCode: "SAVE10", UserID: user.ID, Quantity: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	token, transactions := placeSplitOrder(t, db, user)
+	if err := db.Model(&transactions[0]).Update("total_price", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(PaymentRequest{UserID: user.ID, TransactionIDs: []uint{transactions[0].ID}, QuoteToken: token})
+	if w := serve(ProcessPayment, auth.Principal{Type: auth.TypeUser, ID: user.ID}, nil, nil, body); w.Code != http.StatusBadRequest {
+		t.Errorf("ProcessPayment = %d %s, want %d", w.Code, w.Body, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestResponsesNeverExposePasswords type-checks the handlers and walks the
+// type of everything they pass to c.JSON. A response that could carry a
+// password field, or that serialises a GORM model directly, fails the test.
+func TestResponsesNeverExposePasswords(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []*ast.File
+	for _, file := range pkgs["controller"].Files {
+		files = append(files, file)
+	}
+
+	info := &types.Info{Types: map[ast.Expr]types.TypeAndValue{}}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("main/controller", fset, files, info); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := 0
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) != 2 {
+				return true
+			}
+			selector, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (selector.Sel.Name != "JSON" && selector.Sel.Name != "AbortWithStatusJSON") {
+				return true
+			}
+
+			responses++
+			for _, value := range responseValues(info, call.Args[1]) {
+				if problem := checkResponseType(info.TypeOf(value), map[types.Type]bool{}); problem != "" {
+					t.Errorf("%s: response %s", fset.Position(value.Pos()), problem)
+				}
+			}
+			return true
+		})
+	}
+
+	if responses == 0 {
+		t.Fatal("found no c.JSON calls to check")
+	}
+}
+
+// responseValues expands map literals such as gin.H, whose values are typed
+// as interface{}, into the expressions that actually get serialised.
+func responseValues(info *types.Info, expr ast.Expr) []ast.Expr {
+	literal, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return []ast.Expr{expr}
+	}
+	if _, isMap := info.TypeOf(literal).Underlying().(*types.Map); !isMap {
+		return []ast.Expr{expr}
+	}
+
+	var values []ast.Expr
+	for _, element := range literal.Elts {
+		if pair, ok := element.(*ast.KeyValueExpr); ok {
+			values = append(values, responseValues(info, pair.Value)...)
+		}
+	}
+	return values
+}
+
+// checkResponseType describes why a value of type typ must not be sent to a
+// client, or returns "" when it is safe.
+func checkResponseType(typ types.Type, seen map[types.Type]bool) string {
+	if typ == nil || seen[typ] {
+		return ""
+	}
+	seen[typ] = true
+
+	if named, ok := types.Unalias(typ).(*types.Named); ok {
+		if pkg := named.Obj().Pkg(); pkg != nil && pkg.Path() == "main/models" {
+			return "serialises the model " + named.Obj().Name()
+		}
+	}
+
+	switch underlying := typ.Underlying().(type) {
+	case *types.Pointer:
+		return checkResponseType(underlying.Elem(), seen)
+	case *types.Slice:
+		return checkResponseType(underlying.Elem(), seen)
+	case *types.Array:
+		return checkResponseType(underlying.Elem(), seen)
+	case *types.Map:
+		return checkResponseType(underlying.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < underlying.NumFields(); i++ {
+			field := underlying.Field(i)
+			if !field.Exported() {
+				continue
+			}
+
+			name := field.Name()
+			if tag, ok := reflect.StructTag(underlying.Tag(i)).Lookup("json"); ok {
+				tagName, _, _ := strings.Cut(tag, ",")
+				if tagName == "-" {
+					continue
+				}
+				if tagName != "" {
+					name = tagName
+				}
+			}
+
+			if strings.Contains(strings.ToLower(name), "password") {
+				return "has a password field " + typ.String() + "." + field.Name()
+			}
+			if problem := checkResponseType(field.Type(), seen); problem != "" {
+				return problem
+			}
+		}
+	}
+	return ""
+}
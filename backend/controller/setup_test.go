@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"bytes"
+	"main/auth"
+	"main/config"
+	"main/database"
+	"main/gateway"
+	"main/mail"
+	model "main/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// setUp loads the configuration, starts the mail and payment services and
+// migrates the database the handlers will use.
+func setUp(t *testing.T, dsn string, dialector gorm.Dialector) *gorm.DB {
+	t.Helper()
+
+	t.Setenv("TAILORTECH_DATABASE_DSN", dsn)
+	t.Setenv("TAILORTECH_JWT_SECRET", testSecret)
+	t.Setenv("TAILORTECH_PAYMENT_WEBHOOK_SECRET", testSecret)
+	t.Setenv("TAILORTECH_QUOTE_SECRET", testSecret)
+	t.Setenv("TAILORTECH_MAIL_OUTBOX_DIR", t.TempDir())
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mail.Init(cfg.Mail); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.Init(cfg.PaymentGateway); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Use(db)
+
+	// The catalogue tables come from tailor_tech.sql rather than Migrate.
+	if err := db.AutoMigrate(&model.Product{}, &model.Outfit{}, &model.Request{}, &model.Promo{}, &model.UserPromo{}, &model.TailorPrice{}); err != nil {
+		t.Fatal(err)
+	}
+	model.Migrate()
+
+	gin.SetMode(gin.TestMode)
+	return db
+}
+
+// setUpSQLite runs the handlers against a fresh SQLite database. It serves
+// one request at a time; races need setUpMySQL.
+func setUpSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "controller.db") + "?_txlock=immediate&_pragma=busy_timeout(10000)"
+	return setUp(t, dsn, sqlite.Open(dsn))
+}
+
+// serve runs a handler as the principal, the way the router would after
+// authenticating them.
+func serve(handler gin.HandlerFunc, principal auth.Principal, params gin.Params, header http.Header, body []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		c.Request.Header[name] = values
+	}
+	c.Params = params
+	if principal.ID != 0 {
+		c.Set("principal", principal)
+	}
+	handler(c)
+	return w
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"fmt"
+	"main/auth"
+	"main/escrow"
+	model "main/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestTailorCancelsOnlyThroughSettlement checks that a tailor can't cancel
+// or finish a paid transaction by setting its status, which would leave the
+// payment in escrow, and that the cancel endpoint refunds the buyer.
+func TestTailorCancelsOnlyThroughSettlement(t *testing.T) {
+	db := setUpSQLite(t)
+
+	user := model.User{Name: "Buyer", Email: "buyer@example.com", Money: 200}
+	tailor := model.Tailor{Name: "Tailor", Email: "tailor@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&tailor).Error; err != nil {
+		t.Fatal(err)
+	}
+	transaction := model.Transaction{TransactionDate: time.Now(), UserID: user.ID, TailorID: tailor.ID, Status: model.StatusAccepted, TotalPrice: 200}
+	if err := db.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := escrow.Hold(db, transaction); err != nil {
+		t.Fatal(err)
+	}
+
+	seller := auth.Principal{Type: auth.TypeTailor, ID: tailor.ID}
+	for _, status := range []string{model.StatusCancelled, "cancelled", model.StatusFinished} {
+		body := []byte(fmt.Sprintf(`{"transactionId":%d,"newStatus":%q}`, transaction.ID, status))
+		if w := serve(UpdateOrderStatus, seller, nil, nil, body); w.Code != http.StatusBadRequest {
+			t.Errorf("UpdateOrderStatus to %s = %d %s, want %d", status, w.Code, w.Body, http.StatusBadRequest)
+		}
+	}
+
+	db.First(&transaction, transaction.ID)
+	db.First(&user, user.ID)
+	if transaction.Status != model.StatusAccepted || user.Money != 0 {
+		t.Fatalf("after refused updates, status %s and User.Money %d, want %s and 0", transaction.Status, user.Money, model.StatusAccepted)
+	}
+
+	body := []byte(fmt.Sprintf(`{"transactionId":%d}`, transaction.ID))
+	if w := serve(CancelTransaction, seller, nil, nil, body); w.Code != http.StatusBadRequest {
+		t.Errorf("CancelTransaction without a reason = %d %s, want %d", w.Code, w.Body, http.StatusBadRequest)
+	}
+	body = []byte(fmt.Sprintf(`{"transactionId":%d,"reason":"Out of fabric"}`, transaction.ID))
+	if w := serve(CancelTransaction, seller, nil, nil, body); w.Code != http.StatusOK {
+		t.Fatalf("CancelTransaction = %d %s, want %d", w.Code, w.Body, http.StatusOK)
+	}
+
+	var hold model.EscrowHold
+	db.Where("transaction_id = ?", transaction.ID).First(&hold)
+	db.First(&transaction, transaction.ID)
+	db.First(&user, user.ID)
+	if transaction.Status != model.StatusCancelled || hold.Status != model.HoldRefunded || user.Money != 200 {
+		t.Errorf("after cancelling, status %s, hold %s and User.Money %d, want %s, %s and 200",
+			transaction.Status, hold.Status, user.Money, model.StatusCancelled, model.HoldRefunded)
+	}
+}
@@ -9,14 +9,31 @@ var db *gorm.DB
 
 func GetInstance() *gorm.DB{
 	if db == nil{
-		db = connection()
+		panic("database: GetInstance called before Connect")
 	}
 	return db
 }
 
-func connection() *gorm.DB{
-  dsn := "root:@tcp(127.0.0.1:3306)/tailor_tech?charset=utf8mb4&parseTime=True&loc=Local"
-  db, _:= gorm.Open(mysql.Open(dsn), &gorm.Config{})
+func Connect(dsn string) error{
+  conn, err := connection(dsn)
+  if err != nil{
+    return err
+  }
+  db = conn
+  return nil
+}
+
+// Use makes conn the connection GetInstance returns, for callers that open
+// their own, such as tests.
+func Use(conn *gorm.DB){
+  db = conn
+}
 
-  return db
-}
\ No newline at end of file
+func connection(dsn string) (*gorm.DB, error){
+  db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+  if err != nil{
+    return nil, err
+  }
+
+  return db, nil
+}
@@ -0,0 +1,60 @@
+package dto
+
+import (
+	model "main/models"
+	"time"
+)
+
+type Admin struct {
+	ID    uint
+	Name  string
+	Email string
+}
+
+func NewAdmin(admin model.Admin) Admin {
+	return Admin{ID: admin.ID, Name: admin.Name, Email: admin.Email}
+}
+
+type BalanceAdjustment struct {
+	ID           uint
+	CreatedAt    time.Time
+	AdminID      uint
+	AccountType  string
+	AccountID    uint
+	Amount       int
+	BalanceAfter int
+	Reason       string
+}
+
+func NewBalanceAdjustment(adjustment model.BalanceAdjustment) BalanceAdjustment {
+	return BalanceAdjustment{
+		ID:           adjustment.ID,
+		CreatedAt:    adjustment.CreatedAt,
+		AdminID:      adjustment.AdminID,
+		AccountType:  adjustment.AccountType,
+		AccountID:    adjustment.AccountID,
+		Amount:       adjustment.Amount,
+		BalanceAfter: adjustment.BalanceAfter,
+		Reason:       adjustment.Reason,
+	}
+}
+
+// LoginLock is an account, two-factor or IP subject that is locked out
+// after too many failed attempts.
+type LoginLock struct {
+	Subject     string
+	Failures    int
+	LockedUntil *time.Time
+}
+
+func NewLoginLocks(throttles []model.LoginThrottle) []LoginLock {
+	result := make([]LoginLock, 0, len(throttles))
+	for _, throttle := range throttles {
+		result = append(result, LoginLock{
+			Subject:     throttle.Subject,
+			Failures:    throttle.Failures,
+			LockedUntil: throttle.LockedUntil,
+		})
+	}
+	return result
+}
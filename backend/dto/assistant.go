@@ -0,0 +1,16 @@
+package dto
+
+import model "main/models"
+
+type Assistant struct {
+	ID   uint
+	Name string
+}
+
+func NewAssistants(assistants []model.Assistant) []Assistant {
+	result := make([]Assistant, 0, len(assistants))
+	for _, assistant := range assistants {
+		result = append(result, Assistant{ID: assistant.ID, Name: assistant.Name})
+	}
+	return result
+}
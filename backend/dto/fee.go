@@ -0,0 +1,49 @@
+package dto
+
+import (
+	model "main/models"
+	"time"
+)
+
+// FeeRule is a line of the platform fee schedule. Rate is in basis points.
+type FeeRule struct {
+	ID             uint
+	OutfitCategory string
+	Kind           string
+	TailorTier     string
+	Rate           int
+	EffectiveFrom  time.Time
+	EffectiveUntil *time.Time
+	CreatedBy      uint
+}
+
+func NewFeeRule(rule model.FeeRule) FeeRule {
+	return FeeRule{
+		ID:             rule.ID,
+		OutfitCategory: rule.OutfitCategory,
+		Kind:           rule.Kind,
+		TailorTier:     rule.TailorTier,
+		Rate:           rule.Rate,
+		EffectiveFrom:  rule.EffectiveFrom,
+		EffectiveUntil: rule.EffectiveUntil,
+		CreatedBy:      rule.CreatedBy,
+	}
+}
+
+func NewFeeRules(rules []model.FeeRule) []FeeRule {
+	result := make([]FeeRule, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, NewFeeRule(rule))
+	}
+	return result
+}
+
+// Revenue is the platform's takings for one day, month or tailor: the
+// settled transactions, what buyers paid for them and the fees kept.
+type Revenue struct {
+	Period       string `json:",omitempty"`
+	TailorID     uint   `json:",omitempty"`
+	Transactions int
+	Gross        int
+	Fees         int
+}
@@ -0,0 +1,77 @@
+package dto
+
+import model "main/models"
+
+type Top struct {
+	RequestID       uint
+	Chest           string
+	ShoulderToWaist string
+	Shoulder        string
+	SleveLength     string
+	Waist           string
+	Neck            string
+	Collar          bool
+}
+
+func NewTop(top model.Top) Top {
+	return Top(top)
+}
+
+type Bottom struct {
+	RequestID    uint
+	WaistToAnkle string
+	Waist        string
+	Hip          string
+	Ankle        string
+	Thigh        string
+	Knee         string
+	CuffWidth    string
+}
+
+func NewBottom(bottom model.Bottom) Bottom {
+	return Bottom(bottom)
+}
+
+type Dress struct {
+	RequestID   uint
+	Chest       string
+	Shoulder    string
+	DressLength string
+	Waist       string
+	Hip         string
+}
+
+func NewDress(dress model.Dress) Dress {
+	return Dress(dress)
+}
+
+type Suit struct {
+	RequestID    uint
+	Chest        string
+	Waist        string
+	Hip          string
+	Shoulder     string
+	SleeveLength string
+	JacketLength string
+	Inseam       string
+	Outseam      string
+	Thigh        string
+	Knee         string
+	Ankle        string
+}
+
+func NewSuit(suit model.Suit) Suit {
+	return Suit(suit)
+}
+
+type ToteBag struct {
+	RequestID uint
+	Color     string
+	Material  string
+	Writing   string
+	ImageDesc string
+}
+
+func NewToteBag(toteBag model.ToteBag) ToteBag {
+	return ToteBag(toteBag)
+}
@@ -0,0 +1,67 @@
+package dto
+
+import (
+	model "main/models"
+	"time"
+)
+
+type BankAccount struct {
+	ID            uint
+	BankCode      string
+	AccountNumber string
+	AccountHolder string
+}
+
+func NewBankAccount(bankAccount model.BankAccount) BankAccount {
+	return BankAccount{
+		ID:            bankAccount.ID,
+		BankCode:      bankAccount.BankCode,
+		AccountNumber: bankAccount.AccountNumber,
+		AccountHolder: bankAccount.AccountHolder,
+	}
+}
+
+func NewBankAccounts(bankAccounts []model.BankAccount) []BankAccount {
+	result := make([]BankAccount, 0, len(bankAccounts))
+	for _, bankAccount := range bankAccounts {
+		result = append(result, NewBankAccount(bankAccount))
+	}
+	return result
+}
+
+type Withdrawal struct {
+	ID               uint
+	CreatedAt        time.Time
+	TailorID         uint
+	BankAccount      BankAccount
+	Amount           int
+	Status           string
+	ReviewedAt       *time.Time
+	RejectionReason  string
+	PaidAt           *time.Time
+	PaymentReference string
+}
+
+// NewWithdrawal expects the request's BankAccount to be loaded.
+func NewWithdrawal(request model.WithdrawalRequest) Withdrawal {
+	return Withdrawal{
+		ID:               request.ID,
+		CreatedAt:        request.CreatedAt,
+		TailorID:         request.TailorID,
+		BankAccount:      NewBankAccount(request.BankAccount),
+		Amount:           request.Amount,
+		Status:           request.Status,
+		ReviewedAt:       request.ReviewedAt,
+		RejectionReason:  request.RejectionReason,
+		PaidAt:           request.PaidAt,
+		PaymentReference: request.PaymentReference,
+	}
+}
+
+func NewWithdrawals(requests []model.WithdrawalRequest) []Withdrawal {
+	result := make([]Withdrawal, 0, len(requests))
+	for _, request := range requests {
+		result = append(result, NewWithdrawal(request))
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package dto
+
+import model "main/models"
+
+type Product struct {
+	ID       uint
+	Name     string
+	TailorID uint
+	Desc     string
+	Price    int
+	Size     string
+	ImgUrl   string
+	IsActive bool
+}
+
+func NewProduct(product model.Product) Product {
+	return Product{
+		ID:       product.ID,
+		Name:     product.Name,
+		TailorID: product.TailorID,
+		Desc:     product.Desc,
+		Price:    product.Price,
+		Size:     product.Size,
+		ImgUrl:   product.ImgUrl,
+		IsActive: product.IsActive,
+	}
+}
+
+func NewProducts(products []model.Product) []Product {
+	result := make([]Product, 0, len(products))
+	for _, product := range products {
+		result = append(result, NewProduct(product))
+	}
+	return result
+}
+
+// ProductListing is a product as shown in the catalogue, cart and wishlist,
+// with the tailor's name in place of their ID. Handlers scan it straight
+// from SQL, so the columns must be aliased to product and tailor.
+type ProductListing struct {
+	ID      uint
+	Product string
+	Tailor  string
+	Desc    string
+	Price   int
+	ImgUrl  string
+	Size    string
+}
+
+type Cart struct {
+	TotalPrice int
+	Products   []ProductListing
+}
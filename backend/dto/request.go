@@ -0,0 +1,63 @@
+package dto
+
+import model "main/models"
+
+// Request is a custom tailoring request. Only the measurement matching the
+// request type is filled in.
+type Request struct {
+	ID          uint
+	TailorID    uint
+	UserID      uint
+	Name        string
+	Desc        string
+	Price       uint
+	RequestType uint
+	Top         *Top     `json:",omitempty"`
+	Bottom      *Bottom  `json:",omitempty"`
+	Dress       *Dress   `json:",omitempty"`
+	Suit        *Suit    `json:",omitempty"`
+	ToteBag     *ToteBag `json:",omitempty"`
+}
+
+func NewRequest(request model.Request) Request {
+	result := Request{
+		ID:          request.ID,
+		TailorID:    request.TailorID,
+		UserID:      request.UserID,
+		Name:        request.Name,
+		Desc:        request.Desc,
+		Price:       request.Price,
+		RequestType: request.RequestType,
+	}
+
+	if request.Top.RequestID != 0 {
+		top := NewTop(request.Top)
+		result.Top = &top
+	}
+	if request.Bottom.RequestID != 0 {
+		bottom := NewBottom(request.Bottom)
+		result.Bottom = &bottom
+	}
+	if request.Dress.RequestID != 0 {
+		dress := NewDress(request.Dress)
+		result.Dress = &dress
+	}
+	if request.Suit.RequestID != 0 {
+		suit := NewSuit(request.Suit)
+		result.Suit = &suit
+	}
+	if request.ToteBag.RequestID != 0 {
+		toteBag := NewToteBag(request.ToteBag)
+		result.ToteBag = &toteBag
+	}
+
+	return result
+}
+
+func NewRequests(requests []model.Request) []Request {
+	result := make([]Request, 0, len(requests))
+	for _, request := range requests {
+		result = append(result, NewRequest(request))
+	}
+	return result
+}
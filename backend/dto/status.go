@@ -0,0 +1,40 @@
+package dto
+
+import (
+	model "main/models"
+	"time"
+)
+
+// StatusChange is one entry in a transaction's history.
+type StatusChange struct {
+	FromStatus string
+	ToStatus   string
+	Actor      string
+	ActorID    uint
+	Note       string
+	CreatedAt  time.Time
+}
+
+func NewStatusChanges(changes []model.StatusChange) []StatusChange {
+	result := make([]StatusChange, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, StatusChange{
+			FromStatus: change.FromStatus,
+			ToStatus:   change.ToStatus,
+			Actor:      change.Actor,
+			ActorID:    change.ActorID,
+			Note:       change.Note,
+			CreatedAt:  change.CreatedAt,
+		})
+	}
+	return result
+}
+
+// TimelineStep is a milestone on a transaction's way to being finished,
+// and when it was reached. At is nil for steps reached before history was
+// recorded.
+type TimelineStep struct {
+	Status  string
+	Reached bool
+	At      *time.Time
+}
@@ -0,0 +1,87 @@
+package dto
+
+import (
+	model "main/models"
+	"time"
+)
+
+type Speciality struct {
+	Category string
+	Price    int
+}
+
+// Tailor is the public profile of a tailor.
+type Tailor struct {
+	ID         uint
+	Name       string
+	Email      string
+	Address    string
+	ImgUrl     string
+	Rating     float32
+	Speciality []Speciality
+}
+
+func NewTailor(tailor model.GetTailorFinal) Tailor {
+	specialities := make([]Speciality, 0, len(tailor.Speciality))
+	for _, speciality := range tailor.Speciality {
+		specialities = append(specialities, Speciality{Category: speciality.Category, Price: speciality.Price})
+	}
+
+	return Tailor{
+		ID:         uint(tailor.ID),
+		Name:       tailor.Name,
+		Email:      tailor.Email,
+		Address:    tailor.Address,
+		ImgUrl:     tailor.ImgUrl,
+		Rating:     tailor.Rating,
+		Speciality: specialities,
+	}
+}
+
+// TailorAccount is the profile a tailor sees of their own account, which
+// adds the wallet balance.
+type TailorAccount struct {
+	Tailor
+	Money int
+}
+
+func NewTailorAccount(tailor model.GetTailorFinal) TailorAccount {
+	return TailorAccount{Tailor: NewTailor(tailor), Money: tailor.Money}
+}
+
+// AdminTailor is a tailor as shown on the admin dashboard.
+type AdminTailor struct {
+	ID              uint
+	Name            string
+	Email           string
+	Address         string
+	ImgUrl          string
+	Money           int
+	CreatedAt       time.Time
+	SuspendedAt     *time.Time
+	SuspendedReason string
+	Tier            string
+}
+
+func NewAdminTailor(tailor model.Tailor) AdminTailor {
+	return AdminTailor{
+		ID:              tailor.ID,
+		Name:            tailor.Name,
+		Email:           tailor.Email,
+		Address:         tailor.Address,
+		ImgUrl:          tailor.ImgUrl,
+		Money:           tailor.Money,
+		CreatedAt:       tailor.CreatedAt,
+		SuspendedAt:     tailor.SuspendedAt,
+		SuspendedReason: tailor.SuspendedReason,
+		Tier:            tailor.Tier,
+	}
+}
+
+func NewAdminTailors(tailors []model.Tailor) []AdminTailor {
+	result := make([]AdminTailor, 0, len(tailors))
+	for _, tailor := range tailors {
+		result = append(result, NewAdminTailor(tailor))
+	}
+	return result
+}
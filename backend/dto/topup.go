@@ -0,0 +1,46 @@
+package dto
+
+import (
+	model "main/models"
+	"time"
+)
+
+// TopUp tells the buyer how to pay for a top-up and whether it has arrived.
+// TransactionIDs lists what it pays for when it was started at checkout.
+type TopUp struct {
+	ID                   uint
+	CreatedAt            time.Time
+	Amount               int
+	Method               string
+	Status               string
+	ProviderReference    string
+	VirtualAccountNumber string
+	CheckoutURL          string
+	QRISPayload          string
+	TransactionIDs       []uint
+	ExpiresAt            *time.Time
+	PaidAt               *time.Time
+}
+
+// NewTopUp expects the top-up's Transactions to be loaded.
+func NewTopUp(topUp model.TopUp) TopUp {
+	transactionIDs := make([]uint, 0, len(topUp.Transactions))
+	for _, transaction := range topUp.Transactions {
+		transactionIDs = append(transactionIDs, transaction.ID)
+	}
+
+	return TopUp{
+		ID:                   topUp.ID,
+		CreatedAt:            topUp.CreatedAt,
+		Amount:               topUp.Amount,
+		Method:               topUp.Method,
+		Status:               topUp.Status,
+		ProviderReference:    topUp.ProviderReference,
+		VirtualAccountNumber: topUp.VirtualAccountNumber,
+		CheckoutURL:          topUp.CheckoutURL,
+		QRISPayload:          topUp.QRISPayload,
+		TransactionIDs:       transactionIDs,
+		ExpiresAt:            topUp.ExpiresAt,
+		PaidAt:               topUp.PaidAt,
+	}
+}
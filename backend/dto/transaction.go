@@ -0,0 +1,42 @@
+package dto
+
+import (
+	model "main/models"
+	"time"
+)
+
+type Transaction struct {
+	ID              uint
+	TransactionDate time.Time
+	UserID          uint
+	TailorID        uint
+	Products        []Product
+	Requests        []Request
+	Status          string
+	TotalPrice      uint
+	FeeRate         int
+	FeeAmount       int
+}
+
+func NewTransaction(transaction model.Transaction) Transaction {
+	return Transaction{
+		ID:              transaction.ID,
+		TransactionDate: transaction.TransactionDate,
+		UserID:          transaction.UserID,
+		TailorID:        transaction.TailorID,
+		Products:        NewProducts(transaction.Products),
+		Requests:        NewRequests(transaction.Requests),
+		Status:          transaction.Status,
+		TotalPrice:      transaction.TotalPrice,
+		FeeRate:         transaction.FeeRate,
+		FeeAmount:       transaction.FeeAmount,
+	}
+}
+
+func NewTransactions(transactions []model.Transaction) []Transaction {
+	result := make([]Transaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		result = append(result, NewTransaction(transaction))
+	}
+	return result
+}
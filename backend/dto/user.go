@@ -0,0 +1,60 @@
+// Package dto holds the bodies the API responds with. Handlers map models
+// into these types rather than serialising GORM models, so passwords and
+// internal columns never reach a client.
+package dto
+
+import (
+	model "main/models"
+	"time"
+)
+
+type User struct {
+	ID            uint
+	Name          string
+	Email         string
+	PhoneNumber   string
+	Address       string
+	Points        int
+	Money         int
+	EmailVerified bool
+}
+
+func NewUser(user model.User) User {
+	return User{
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		PhoneNumber:   user.PhoneNumber,
+		Address:       user.Address,
+		Points:        user.Points,
+		Money:         user.Money,
+		EmailVerified: user.IsEmailVerified(),
+	}
+}
+
+// AdminUser is a user as shown on the admin dashboard.
+type AdminUser struct {
+	User
+	CreatedAt       time.Time
+	EmailVerifiedAt *time.Time
+	SuspendedAt     *time.Time
+	SuspendedReason string
+}
+
+func NewAdminUser(user model.User) AdminUser {
+	return AdminUser{
+		User:            NewUser(user),
+		CreatedAt:       user.CreatedAt,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		SuspendedAt:     user.SuspendedAt,
+		SuspendedReason: user.SuspendedReason,
+	}
+}
+
+func NewAdminUsers(users []model.User) []AdminUser {
+	result := make([]AdminUser, 0, len(users))
+	for _, user := range users {
+		result = append(result, NewAdminUser(user))
+	}
+	return result
+}
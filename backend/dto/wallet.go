@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+// StatementLine is one movement on a wallet. Handlers scan it straight from
+// the postings joined with their journal entries.
+type StatementLine struct {
+	ID            uint
+	CreatedAt     time.Time
+	Kind          string
+	Description   string
+	TransactionID *uint
+	Amount        int
+	BalanceAfter  int
+}
+
+type Statement struct {
+	Balance int
+	Lines   []StatementLine
+}
@@ -0,0 +1,206 @@
+// Package escrow holds a buyer's payment for a transaction until the buyer
+// confirms receipt, then pays the tailor, or refunds the buyer on
+// cancellation. Every step posts to the ledger and must run inside a DB
+// transaction.
+package escrow
+
+import (
+	"errors"
+	"fmt"
+	"main/fees"
+	"main/ledger"
+	model "main/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAlreadyHeld = errors.New("escrow: transaction already paid")
+	ErrNoHold      = errors.New("escrow: no payment held for transaction")
+)
+
+// Hold moves the transaction's TotalPrice from the buyer's wallet into
+// escrow.
+func Hold(tx *gorm.DB, transaction model.Transaction) (model.EscrowHold, error) {
+	var count int64
+	if err := tx.Model(&model.EscrowHold{}).Where("transaction_id = ?", transaction.ID).Count(&count).Error; err != nil {
+		return model.EscrowHold{}, err
+	}
+	if count > 0 {
+		return model.EscrowHold{}, ErrAlreadyHeld
+	}
+
+	hold := model.EscrowHold{
+		TransactionID: transaction.ID,
+		UserID:        transaction.UserID,
+		TailorID:      transaction.TailorID,
+		Amount:        int(transaction.TotalPrice),
+		Status:        model.HoldHeld,
+	}
+	if err := tx.Create(&hold).Error; err != nil {
+		return model.EscrowHold{}, err
+	}
+
+	_, err := ledger.Post(tx, ledger.Entry{
+		Kind:          model.EntryPayment,
+		Description:   fmt.Sprintf("Payment for transaction %d", transaction.ID),
+		TransactionID: &transaction.ID,
+		Legs: []ledger.Leg{
+			{Account: ledger.UserWallet(transaction.UserID), Amount: -hold.Amount},
+			{Account: ledger.Escrow, Amount: hold.Amount},
+		},
+	})
+	return hold, err
+}
+
+// Release pays the held amount to the tailor, less the platform fee from
+// the fee schedule on the items' price, and records the fee on the
+// transaction.
+func Release(tx *gorm.DB, transactionID uint) (model.EscrowHold, error) {
+	hold, err := lockHeld(tx, transactionID)
+	if err != nil {
+		return model.EscrowHold{}, err
+	}
+
+	var transaction model.Transaction
+	if err := tx.Preload("Products").Preload("Requests").First(&transaction, transactionID).Error; err != nil {
+		return model.EscrowHold{}, err
+	}
+
+	charge, err := fees.For(tx, transaction, fees.Base(transaction, hold.Amount))
+	if err != nil {
+		return model.EscrowHold{}, err
+	}
+	fee := charge.Amount
+	tailorAmount := hold.Amount - fee
+
+	if err := tx.Model(&transaction).Updates(map[string]interface{}{
+		"fee_rule_id": charge.RuleID,
+		"fee_rate":    charge.Rate,
+		"fee_amount":  charge.Amount,
+	}).Error; err != nil {
+		return model.EscrowHold{}, err
+	}
+
+	_, err = ledger.Post(tx, ledger.Entry{
+		Kind:          model.EntrySettlement,
+		Description:   fmt.Sprintf("Settlement of transaction %d", transactionID),
+		TransactionID: &transactionID,
+		Legs: []ledger.Leg{
+			{Account: ledger.Escrow, Amount: -hold.Amount},
+			{Account: ledger.TailorWallet(hold.TailorID), Amount: tailorAmount},
+			{Account: ledger.PlatformFees, Amount: fee},
+		},
+	})
+	if err != nil {
+		return model.EscrowHold{}, err
+	}
+
+	return settle(tx, hold, model.HoldReleased, tailorAmount, fee)
+}
+
+// Refund returns the held amount to the buyer.
+func Refund(tx *gorm.DB, transactionID uint) (model.EscrowHold, error) {
+	hold, err := lockHeld(tx, transactionID)
+	if err != nil {
+		return model.EscrowHold{}, err
+	}
+
+	_, err = ledger.Post(tx, ledger.Entry{
+		Kind:          model.EntryRefund,
+		Description:   fmt.Sprintf("Refund of transaction %d", transactionID),
+		TransactionID: &transactionID,
+		Legs: []ledger.Leg{
+			{Account: ledger.Escrow, Amount: -hold.Amount},
+			{Account: ledger.UserWallet(hold.UserID), Amount: hold.Amount},
+		},
+	})
+	if err != nil {
+		return model.EscrowHold{}, err
+	}
+
+	return settle(tx, hold, model.HoldRefunded, 0, 0)
+}
+
+// paidStatuses are the statuses in which a transaction's payment should be
+// held in escrow.
+var paidStatuses = []string{
+	model.StatusPaid,
+	model.StatusAccepted,
+	model.StatusInProgress,
+	model.StatusShipped,
+	model.StatusDelivered,
+	model.StatusDisputed,
+}
+
+// AdoptLegacy puts transactions that were paid before escrow existed into
+// escrow, so they can be released or refunded like any other. Their buyers
+// were charged when they paid, so the held amount is carried over from
+// outside the ledger. Transactions already in escrow are left alone, so it
+// is safe to run on every startup. It returns how many were adopted.
+func AdoptLegacy(db *gorm.DB) (int, error) {
+	var transactions []model.Transaction
+	if err := db.Where("status IN ?", paidStatuses).
+		Where("id NOT IN (?)", db.Model(&model.EscrowHold{}).Select("transaction_id")).
+		Find(&transactions).Error; err != nil {
+		return 0, err
+	}
+
+	adopted := 0
+	for _, transaction := range transactions {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			hold := model.EscrowHold{
+				TransactionID: transaction.ID,
+				UserID:        transaction.UserID,
+				TailorID:      transaction.TailorID,
+				Amount:        int(transaction.TotalPrice),
+				Status:        model.HoldHeld,
+			}
+			if err := tx.Create(&hold).Error; err != nil {
+				return err
+			}
+
+			_, err := ledger.Post(tx, ledger.Entry{
+				Kind:          model.EntryOpeningBalance,
+				Description:   fmt.Sprintf("Payment for transaction %d, made before escrow", transaction.ID),
+				TransactionID: &transaction.ID,
+				Legs: []ledger.Leg{
+					{Account: ledger.External, Amount: -hold.Amount},
+					{Account: ledger.Escrow, Amount: hold.Amount},
+				},
+			})
+			return err
+		})
+		if err != nil {
+			return adopted, fmt.Errorf("escrow: adopting transaction %d: %w", transaction.ID, err)
+		}
+		adopted++
+	}
+	return adopted, nil
+}
+
+func lockHeld(tx *gorm.DB, transactionID uint) (model.EscrowHold, error) {
+	var hold model.EscrowHold
+	if tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("transaction_id = ? AND status = ?", transactionID, model.HoldHeld).Limit(1).Find(&hold).RowsAffected == 0 {
+		return model.EscrowHold{}, ErrNoHold
+	}
+	return hold, nil
+}
+
+func settle(tx *gorm.DB, hold model.EscrowHold, status string, tailorAmount, fee int) (model.EscrowHold, error) {
+	now := time.Now()
+	hold.Status = status
+	hold.TailorAmount = tailorAmount
+	hold.FeeAmount = fee
+	hold.SettledAt = &now
+
+	err := tx.Model(&hold).Updates(map[string]interface{}{
+		"status":        hold.Status,
+		"tailor_amount": hold.TailorAmount,
+		"fee_amount":    hold.FeeAmount,
+		"settled_at":    hold.SettledAt,
+	}).Error
+	return hold, err
+}
@@ -0,0 +1,132 @@
+package escrow
+
+import (
+	"errors"
+	"main/ledger"
+	model "main/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "escrow.db") + "?_pragma=busy_timeout(10000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&model.User{}, &model.Tailor{}, &model.Product{}, &model.Outfit{}, &model.Transaction{}, &model.FeeRule{},
+		&model.EscrowHold{}, &model.LedgerAccount{}, &model.JournalEntry{}, &model.Posting{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// legacyTransaction stores a transaction paid the way payments worked before
+// escrow: the buyer was charged and nothing was held. It is a 180 shirt
+// with 20 shipping.
+func legacyTransaction(t *testing.T, db *gorm.DB, status string) (model.User, model.Tailor, model.Transaction) {
+	t.Helper()
+
+	user := model.User{Name: "Buyer", Email: "buyer@example.com", Money: 40}
+	tailor := model.Tailor{Name: "Tailor", Email: "tailor@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&tailor).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	transaction := model.Transaction{
+		TransactionDate: time.Now(),
+		UserID:          user.ID,
+		TailorID:        tailor.ID,
+		Status:          status,
+		TotalPrice:      200,
+		Products:        []model.Product{{Name: "Shirt", TailorID: tailor.ID, Price: 180}},
+	}
+	if err := db.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user, tailor, transaction
+}
+
+func TestAdoptLegacyLetsPaidTransactionsSettle(t *testing.T) {
+	tests := []struct {
+		name       string
+		settle     func(tx *gorm.DB, transactionID uint) (model.EscrowHold, error)
+		userMoney  int
+		tailorPaid int
+	}{
+		// The 5% default fee is charged on the shirt, not the shipping.
+		{name: "release", settle: Release, userMoney: 40, tailorPaid: 191},
+		{name: "refund", settle: Refund, userMoney: 240, tailorPaid: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			user, tailor, transaction := legacyTransaction(t, db, model.StatusAccepted)
+
+			if _, err := Release(db, transaction.ID); !errors.Is(err, ErrNoHold) {
+				t.Fatalf("Release() before adoption error = %v, want ErrNoHold", err)
+			}
+
+			adopted, err := AdoptLegacy(db)
+			if err != nil || adopted != 1 {
+				t.Fatalf("AdoptLegacy() = %d, %v, want 1", adopted, err)
+			}
+			if adopted, err := AdoptLegacy(db); err != nil || adopted != 0 {
+				t.Fatalf("second AdoptLegacy() = %d, %v, want 0", adopted, err)
+			}
+
+			err = db.Transaction(func(tx *gorm.DB) error {
+				_, err := tt.settle(tx, transaction.ID)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db.First(&user, user.ID)
+			db.First(&tailor, tailor.ID)
+			if user.Money != tt.userMoney || tailor.Money != tt.tailorPaid {
+				t.Errorf("User.Money, Tailor.Money = %d, %d, want %d, %d", user.Money, tailor.Money, tt.userMoney, tt.tailorPaid)
+			}
+
+			escrow, err := ledger.GetAccount(db, ledger.Escrow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if escrow.Balance != 0 {
+				t.Errorf("escrow balance = %d, want 0", escrow.Balance)
+			}
+
+			discrepancies, unbalanced, err := ledger.Reconcile(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(discrepancies) != 0 || len(unbalanced) != 0 {
+				t.Errorf("Reconcile() = %+v, %v, want no discrepancies", discrepancies, unbalanced)
+			}
+		})
+	}
+}
+
+func TestAdoptLegacySkipsUnpaidAndSettledTransactions(t *testing.T) {
+	for _, status := range []string{model.StatusPendingPayment, model.StatusFinished, model.StatusCancelled} {
+		db := newTestDB(t)
+		legacyTransaction(t, db, status)
+
+		if adopted, err := AdoptLegacy(db); err != nil || adopted != 0 {
+			t.Errorf("AdoptLegacy() with a %s transaction = %d, %v, want 0", status, adopted, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package fees works out the platform's cut of a transaction from the fee
+// schedule stored in the database.
+package fees
+
+import (
+	model "main/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DefaultRate, in basis points, applies when no rule in the schedule
+// matches: 5%.
+const DefaultRate = 500
+
+// Charge is the fee for one transaction and where its rate came from.
+// RuleID is nil when the default rate applied.
+type Charge struct {
+	RuleID *uint
+	Rate   int
+	Amount int
+}
+
+// Subject is what a fee rule is matched against.
+type Subject struct {
+	Kind           string
+	OutfitCategory string
+	TailorTier     string
+}
+
+// For prices the fee on amount for the transaction, which must have its
+// Requests loaded. Rates are those in effect when the transaction was
+// placed, for the tailor's tier at the time, so neither changing the
+// schedule nor moving the tailor to another tier reprices an open order.
+func For(tx *gorm.DB, transaction model.Transaction, amount int) (Charge, error) {
+	subject, err := subjectOf(tx, transaction)
+	if err != nil {
+		return Charge{}, err
+	}
+
+	var rules []model.FeeRule
+	if err := tx.Where("effective_from <= ? AND (effective_until IS NULL OR effective_until > ?)", transaction.TransactionDate, transaction.TransactionDate).
+		Find(&rules).Error; err != nil {
+		return Charge{}, err
+	}
+
+	charge := Charge{Rate: DefaultRate}
+	if rule, ok := Match(rules, subject); ok {
+		charge.RuleID = &rule.ID
+		charge.Rate = rule.Rate
+	}
+	charge.Amount = amount * charge.Rate / 10000
+	return charge, nil
+}
+
+// Base is what the fee is charged on: the transaction's items, without
+// shipping and before the promo discount, but never more than was paid.
+// Transactions placed before Subtotal was recorded are priced from their
+// Products and Requests, which must be loaded.
+func Base(transaction model.Transaction, paid int) int {
+	base := int(transaction.Subtotal)
+	if base == 0 {
+		for _, product := range transaction.Products {
+			base += product.Price
+		}
+		for _, request := range transaction.Requests {
+			base += int(request.Price)
+		}
+	}
+	return min(base, paid)
+}
+
+// Match picks the rule that applies to the subject: the one matching the
+// most of kind, category and tier, then the one that took effect last.
+func Match(rules []model.FeeRule, subject Subject) (model.FeeRule, bool) {
+	var best model.FeeRule
+	bestScore := -1
+	for _, rule := range rules {
+		score, ok := specificity(rule, subject)
+		if !ok {
+			continue
+		}
+		if score > bestScore ||
+			(score == bestScore && rule.EffectiveFrom.After(best.EffectiveFrom)) ||
+			(score == bestScore && rule.EffectiveFrom.Equal(best.EffectiveFrom) && rule.ID > best.ID) {
+			best, bestScore = rule, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// specificity counts the rule's conditions, or reports that one of them
+// doesn't hold for the subject.
+func specificity(rule model.FeeRule, subject Subject) (int, bool) {
+	score := 0
+	for _, condition := range [][2]string{
+		{rule.Kind, subject.Kind},
+		{rule.OutfitCategory, subject.OutfitCategory},
+		{rule.TailorTier, subject.TailorTier},
+	} {
+		if condition[0] == "" {
+			continue
+		}
+		if !strings.EqualFold(condition[0], condition[1]) {
+			return 0, false
+		}
+		score++
+	}
+	return score, true
+}
+
+// subjectOf describes the transaction. Only custom requests have an outfit
+// category; ready-made products match rules for any category. Transactions
+// placed before the tier was recorded on them use the tailor's current one.
+func subjectOf(tx *gorm.DB, transaction model.Transaction) (Subject, error) {
+	subject := Subject{Kind: model.FeeKindProduct, TailorTier: model.TailorTierStandard}
+
+	tier := transaction.TailorTier
+	if tier == "" {
+		var tailor model.Tailor
+		if err := tx.Select("id", "tier").First(&tailor, transaction.TailorID).Error; err != nil {
+			return Subject{}, err
+		}
+		tier = tailor.Tier
+	}
+	if tier != "" {
+		subject.TailorTier = tier
+	}
+
+	if len(transaction.Requests) > 0 {
+		subject.Kind = model.FeeKindRequest
+
+		var outfit model.Outfit
+		if tx.Limit(1).Find(&outfit, transaction.Requests[0].RequestType).RowsAffected > 0 {
+			subject.OutfitCategory = outfit.Category
+		}
+	}
+	return subject, nil
+}
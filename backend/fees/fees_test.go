@@ -0,0 +1,254 @@
+package fees
+
+import (
+	model "main/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func rule(id uint, rate int, kind, category, tier string, from time.Time) model.FeeRule {
+	r := model.FeeRule{Kind: kind, OutfitCategory: category, TailorTier: tier, Rate: rate, EffectiveFrom: from}
+	r.ID = id
+	return r
+}
+
+func TestMatch(t *testing.T) {
+	request := Subject{Kind: model.FeeKindRequest, OutfitCategory: "Kebaya", TailorTier: model.TailorTierPremium}
+
+	tests := []struct {
+		name    string
+		rules   []model.FeeRule
+		subject Subject
+		want    uint
+		found   bool
+	}{
+		{
+			name:    "no rules",
+			subject: request,
+		},
+		{
+			name:    "only rules for something else",
+			rules:   []model.FeeRule{rule(1, 300, model.FeeKindProduct, "", "", epoch), rule(2, 300, "", "Suit", "", epoch)},
+			subject: request,
+		},
+		{
+			name:    "a rule for anything",
+			rules:   []model.FeeRule{rule(1, 300, "", "", "", epoch)},
+			subject: request,
+			want:    1,
+			found:   true,
+		},
+		{
+			name:    "more conditions win",
+			rules:   []model.FeeRule{rule(1, 300, model.FeeKindRequest, "", "", epoch), rule(2, 600, model.FeeKindRequest, "", model.TailorTierPremium, epoch), rule(3, 900, "", "", "", epoch)},
+			subject: request,
+			want:    2,
+			found:   true,
+		},
+		{
+			name:    "more conditions win over a later rule",
+			rules:   []model.FeeRule{rule(1, 300, "", "Kebaya", model.TailorTierPremium, epoch), rule(2, 600, "", "Kebaya", "", epoch.AddDate(0, 1, 0))},
+			subject: request,
+			want:    1,
+			found:   true,
+		},
+		{
+			name:    "category matches case-insensitively",
+			rules:   []model.FeeRule{rule(1, 300, "", "KEBAYA", "", epoch)},
+			subject: request,
+			want:    1,
+			found:   true,
+		},
+		{
+			name:    "equally specific, the later rule wins",
+			rules:   []model.FeeRule{rule(2, 300, "", "Kebaya", "", epoch.AddDate(0, 1, 0)), rule(1, 600, "", "", model.TailorTierPremium, epoch)},
+			subject: request,
+			want:    2,
+			found:   true,
+		},
+		{
+			name:    "equally specific and dated, the newer rule wins",
+			rules:   []model.FeeRule{rule(4, 300, "", "Kebaya", "", epoch), rule(3, 600, "", "Kebaya", "", epoch)},
+			subject: request,
+			want:    4,
+			found:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, found := Match(tt.rules, tt.subject)
+		if found != tt.found || got.ID != tt.want {
+			t.Errorf("%s: Match() = rule %d, %v, want rule %d, %v", tt.name, got.ID, found, tt.want, tt.found)
+		}
+	}
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "fees.db")
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Tailor{}, &model.Outfit{}, &model.Transaction{}, &model.FeeRule{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// ruleID is the rule a charge was made under, or 0 for the default rate.
+func ruleID(charge Charge) uint {
+	if charge.RuleID == nil {
+		return 0
+	}
+	return *charge.RuleID
+}
+
+func TestForUsesTheRulesInEffectWhenPlaced(t *testing.T) {
+	db := newTestDB(t)
+
+	tailor := model.Tailor{Name: "Tailor", Email: "tailor@example.com", Tier: model.TailorTierStandard}
+	if err := db.Create(&tailor).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	placed := epoch.AddDate(0, 2, 0)
+	ended := placed
+	rules := []model.FeeRule{
+		// Ended exactly when the order was placed, so no longer in effect.
+		{Kind: model.FeeKindProduct, Rate: 100, EffectiveFrom: epoch, EffectiveUntil: &ended},
+		// Took effect exactly when the order was placed.
+		{Kind: model.FeeKindProduct, Rate: 700, EffectiveFrom: placed},
+		// Not yet in effect.
+		{Kind: model.FeeKindProduct, TailorTier: model.TailorTierStandard, Rate: 900, EffectiveFrom: placed.Add(time.Second)},
+	}
+	if err := db.Create(&rules).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		placed   time.Time
+		wantRule uint
+		wantRate int
+	}{
+		{"before any rule", epoch.Add(-time.Second), 0, DefaultRate},
+		{"while the first rule applied", placed.Add(-time.Second), rules[0].ID, 100},
+		{"at the boundary", placed, rules[1].ID, 700},
+		{"after the last rule took effect", placed.AddDate(0, 0, 1), rules[2].ID, 900},
+	}
+
+	for _, tt := range tests {
+		transaction := model.Transaction{TransactionDate: tt.placed, TailorID: tailor.ID}
+		charge, err := For(db, transaction, 2000)
+		if err != nil {
+			t.Fatalf("%s: For() error = %v", tt.name, err)
+		}
+
+		if ruleID(charge) != tt.wantRule {
+			t.Errorf("%s: For() rule = %d, want %d", tt.name, ruleID(charge), tt.wantRule)
+		}
+		if charge.Rate != tt.wantRate || charge.Amount != 2000*tt.wantRate/10000 {
+			t.Errorf("%s: For() = rate %d, amount %d, want rate %d, amount %d", tt.name, charge.Rate, charge.Amount, tt.wantRate, 2000*tt.wantRate/10000)
+		}
+	}
+}
+
+func TestForDescribesCustomRequests(t *testing.T) {
+	db := newTestDB(t)
+
+	tailor := model.Tailor{Name: "Tailor", Email: "tailor@example.com", Tier: model.TailorTierPremium}
+	outfit := model.Outfit{Category: "Kebaya"}
+	if err := db.Create(&tailor).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&outfit).Error; err != nil {
+		t.Fatal(err)
+	}
+	rules := []model.FeeRule{
+		{Kind: model.FeeKindProduct, Rate: 100, EffectiveFrom: epoch},
+		{Kind: model.FeeKindRequest, OutfitCategory: "Kebaya", TailorTier: model.TailorTierPremium, Rate: 800, EffectiveFrom: epoch},
+	}
+	if err := db.Create(&rules).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	transaction := model.Transaction{
+		TransactionDate: epoch.AddDate(0, 1, 0),
+		TailorID:        tailor.ID,
+		Requests:        []model.Request{{RequestType: outfit.ID}},
+	}
+	charge, err := For(db, transaction, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ruleID(charge) != rules[1].ID || charge.Amount != 80 {
+		t.Errorf("For() = rule %d, amount %d, want rule %d, amount 80", ruleID(charge), charge.Amount, rules[1].ID)
+	}
+}
+
+func TestBase(t *testing.T) {
+	tests := []struct {
+		name        string
+		transaction model.Transaction
+		paid        int
+		want        int
+	}{
+		{"shipping is not charged", model.Transaction{Subtotal: 180}, 200, 180},
+		{"a discount below the items' price", model.Transaction{Subtotal: 180}, 150, 150},
+		{"placed before the subtotal was recorded",
+			model.Transaction{Products: []model.Product{{Price: 100}, {Price: 50}}, Requests: []model.Request{{Price: 30}}}, 200, 180},
+		{"no items", model.Transaction{}, 200, 0},
+	}
+
+	for _, tt := range tests {
+		if got := Base(tt.transaction, tt.paid); got != tt.want {
+			t.Errorf("%s: Base() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestForUsesTheTierTheOrderWasPlacedAt(t *testing.T) {
+	db := newTestDB(t)
+
+	// The tailor has since been moved up to premium.
+	tailor := model.Tailor{Name: "Tailor", Email: "tailor@example.com", Tier: model.TailorTierPremium}
+	if err := db.Create(&tailor).Error; err != nil {
+		t.Fatal(err)
+	}
+	rules := []model.FeeRule{
+		{TailorTier: model.TailorTierStandard, Rate: 300, EffectiveFrom: epoch},
+		{TailorTier: model.TailorTierPremium, Rate: 900, EffectiveFrom: epoch},
+	}
+	if err := db.Create(&rules).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		tier     string
+		wantRate int
+	}{
+		{"placed as standard", model.TailorTierStandard, 300},
+		{"placed before tiers were recorded", "", 900},
+	}
+
+	for _, tt := range tests {
+		transaction := model.Transaction{TransactionDate: epoch.AddDate(0, 1, 0), TailorID: tailor.ID, TailorTier: tt.tier}
+		charge, err := For(db, transaction, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if charge.Rate != tt.wantRate {
+			t.Errorf("%s: For() rate = %d, want %d", tt.name, charge.Rate, tt.wantRate)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package gateway collects wallet top-ups through an external payment
+// provider. A top-up starts as an intent that tells the buyer how to pay;
+// the provider later reports the outcome through a signed webhook, and only
+// then is the wallet credited.
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"main/config"
+	"net/http"
+	"time"
+)
+
+// Payment methods, as sent in the paymentMethod field of payments and
+// top-ups. MethodWallet pays from the TailorPay balance and never reaches a
+// provider.
+const (
+	MethodWallet         = "TailorPay"
+	MethodVirtualAccount = "VirtualAccount"
+	MethodEWallet        = "EWallet"
+	MethodQRIS           = "QRIS"
+)
+
+// IsProviderMethod reports whether method is collected by the provider.
+func IsProviderMethod(method string) bool {
+	return method == MethodVirtualAccount || method == MethodEWallet || method == MethodQRIS
+}
+
+// IntentRequest asks the provider to collect Amount. Reference is our own
+// ID for the top-up and comes back in its webhooks.
+type IntentRequest struct {
+	Reference     string
+	Amount        int
+	Method        string
+	CustomerName  string
+	CustomerEmail string
+}
+
+// Intent tells the buyer how to pay: into VirtualAccountNumber, or through
+// the e-wallet page at CheckoutURL. QRIS intents carry neither; the QR code
+// is built from ProviderReference.
+type Intent struct {
+	ProviderReference    string
+	VirtualAccountNumber string
+	CheckoutURL          string
+	ExpiresAt            time.Time
+}
+
+// Webhook event statuses.
+const (
+	EventPaid    = "paid"
+	EventFailed  = "failed"
+	EventExpired = "expired"
+)
+
+// Event is a verified webhook from the provider.
+type Event struct {
+	ProviderReference string
+	Reference         string
+	Status            string
+	Amount            int
+}
+
+// Provider is a payment provider that can collect top-ups.
+type Provider interface {
+	Name() string
+	CreateIntent(request IntentRequest) (Intent, error)
+	// ParseWebhook checks the callback's signature before decoding it, and
+	// returns ErrInvalidSignature when it doesn't match.
+	ParseWebhook(header http.Header, body []byte) (Event, error)
+}
+
+var (
+	ErrInvalidSignature  = errors.New("gateway: invalid webhook signature")
+	ErrUnsupportedMethod = errors.New("gateway: unsupported payment method")
+)
+
+var provider Provider
+
+// Init builds the provider selected by the config.
+func Init(cfg config.PaymentGatewayConfig) error {
+	switch cfg.Provider {
+	case "mock":
+		provider = &MockProvider{Secret: cfg.WebhookSecret}
+	default:
+		return fmt.Errorf("unknown payment provider %q", cfg.Provider)
+	}
+	return nil
+}
+
+func Get() Provider {
+	if provider == nil {
+		panic("gateway: Get called before Init")
+	}
+	return provider
+}
+
+// Sign returns the hex HMAC-SHA256 of body under secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is body's HMAC under secret, in
+// constant time.
+func Verify(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,184 @@
+// Package idempotency makes retried requests safe. A client that sends an
+// Idempotency-Key header gets the first response for that key replayed on
+// every retry, instead of the request running again.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"main/auth"
+	"main/database"
+	model "main/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// TTL is how long a key is remembered. After that it can be used again.
+	TTL = 24 * time.Hour
+	// ProcessingTimeout is how long a key stays reserved for a request that
+	// never stored its response, say because the server crashed meanwhile.
+	// A retry after that runs the request again.
+	ProcessingTimeout = time.Minute
+
+	maxKeyLength = 128
+	maxBodySize  = 1 << 20
+)
+
+// Middleware replays the stored response when a request repeats an
+// Idempotency-Key, and answers 409 Conflict when the key was used for a
+// different request or the first one hasn't finished. Keys are scoped to the
+// principal, so it must run after auth.Authenticate. Server errors are not
+// stored, so the client can retry them, and neither is a request that never
+// finishes: its key lapses after ProcessingTimeout.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 128 characters"})
+			return
+		}
+
+		principal, _ := auth.GetPrincipal(c)
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := model.IdempotencyKey{
+			PrincipalType: principal.Type,
+			PrincipalID:   principal.ID,
+			Key:           key,
+			Fingerprint:   fingerprint(c.Request, body),
+		}
+
+		stored, claimed, err := claim(&record)
+		if err != nil {
+			log.Printf("Failed to store idempotency key: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+			return
+		}
+		if !claimed {
+			replay(c, record, stored)
+			return
+		}
+
+		db := database.GetInstance()
+		saved := false
+		// Release the key if the handler fails outright or panics.
+		defer func() {
+			if !saved {
+				db.Delete(&record)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		err = db.Model(&record).Updates(map[string]interface{}{
+			"status":       status,
+			"content_type": recorder.Header().Get("Content-Type"),
+			"body":         recorder.body.Bytes(),
+		}).Error
+		if err != nil {
+			log.Printf("Failed to store response for idempotency key %d: %v", record.ID, err)
+			return
+		}
+		saved = true
+	}
+}
+
+// claim stores record as in progress and reports whether this request got
+// the key. When it didn't, the record already stored for the key is
+// returned instead.
+func claim(record *model.IdempotencyKey) (model.IdempotencyKey, bool, error) {
+	db := database.GetInstance()
+	scope := db.Where("principal_type = ? AND principal_id = ? AND idempotency_key = ?", record.PrincipalType, record.PrincipalID, record.Key)
+
+	// Forget the key if it has outlived its TTL, or was reserved by a request
+	// that never finished.
+	now := time.Now()
+	if err := scope.Session(&gorm.Session{}).
+		Where("created_at < ? OR (status = 0 AND created_at < ?)", now.Add(-TTL), now.Add(-ProcessingTimeout)).
+		Delete(&model.IdempotencyKey{}).Error; err != nil {
+		return model.IdempotencyKey{}, false, err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return model.IdempotencyKey{}, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return *record, true, nil
+	}
+
+	var stored model.IdempotencyKey
+	err := scope.Session(&gorm.Session{}).First(&stored).Error
+	return stored, false, err
+}
+
+func replay(c *gin.Context, record, stored model.IdempotencyKey) {
+	switch {
+	case stored.Fingerprint != record.Fingerprint:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "This Idempotency-Key was already used for a different request"})
+	case stored.Status == 0:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+	default:
+		c.Header(ReplayedHeader, "true")
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+	}
+}
+
+// fingerprint identifies the request a key was first used for.
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.Path+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,185 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"main/database"
+	"main/lifecycle"
+	"main/mail"
+	model "main/models"
+	"main/settlement"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// delivery is a transaction waiting in Delivered and when it got there.
+type delivery struct {
+	TransactionID uint
+	DeliveredAt   time.Time
+}
+
+// deliveredBefore lists the transactions still Delivered that were last
+// delivered before cutoff.
+func deliveredBefore(db *gorm.DB, cutoff time.Time) ([]delivery, error) {
+	// A transaction delivered again after a dispute has several deliveries;
+	// only the latest counts.
+	latest := db.Session(&gorm.Session{NewDB: true}).Model(&model.StatusChange{}).
+		Select("transaction_id, MAX(created_at) AS delivered_at").
+		Where("to_status = ?", model.StatusDelivered).
+		Group("transaction_id")
+
+	var deliveries []delivery
+	err := db.Model(&model.StatusChange{}).
+		Joins("JOIN (?) AS latest ON latest.transaction_id = status_changes.transaction_id AND latest.delivered_at = status_changes.created_at", latest).
+		Joins("JOIN transactions ON transactions.id = status_changes.transaction_id AND transactions.deleted_at IS NULL").
+		Where("transactions.status = ? AND status_changes.to_status = ? AND status_changes.created_at < ?", model.StatusDelivered, model.StatusDelivered, cutoff).
+		Select("status_changes.transaction_id, status_changes.created_at AS delivered_at").
+		Order("status_changes.created_at").
+		Scan(&deliveries).Error
+	return deliveries, err
+}
+
+// RemindAutoComplete tells the buyer and tailor of each transaction that
+// will be finished automatically within notice that it is about to be. Each
+// transaction is only announced once, even if runs overlap. It returns how
+// many were announced.
+func RemindAutoComplete(now time.Time, after, notice time.Duration) (int, error) {
+	db := database.GetInstance()
+
+	deliveries, err := deliveredBefore(db, now.Add(notice-after))
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for _, delivered := range deliveries {
+		// Claim the notice before sending it, so that of two runs racing over
+		// the same transaction only one emails.
+		claim := db.Model(&model.Transaction{}).
+			Where("id = ? AND completion_notice_at IS NULL", delivered.TransactionID).
+			Update("completion_notice_at", now)
+		if claim.Error != nil {
+			return reminded, claim.Error
+		}
+		if claim.RowsAffected != 1 {
+			continue
+		}
+
+		var transaction model.Transaction
+		if err := db.First(&transaction, delivered.TransactionID).Error; err != nil {
+			return reminded, err
+		}
+
+		// Transactions are only completed once notice has passed since they
+		// were announced, which after downtime is later than planned.
+		completeAt := delivered.DeliveredAt.Add(after)
+		if earliest := now.Add(notice); completeAt.Before(earliest) {
+			completeAt = earliest
+		}
+		completeOn := completeAt.Format("2 January 2006")
+		err := notify(transaction,
+			fmt.Sprintf("Your order #%d will be completed on %s", transaction.ID, completeOn),
+			fmt.Sprintf("Order #%d was delivered on %s. If you received it, please confirm it in the app. "+
+				"If something is wrong, open a dispute before %s; otherwise the order will be completed automatically "+
+				"and the payment released to the tailor.", transaction.ID, delivered.DeliveredAt.Format("2 January 2006"), completeOn),
+			fmt.Sprintf("Order #%d will be completed on %s", transaction.ID, completeOn),
+			fmt.Sprintf("Order #%d was delivered on %s. Unless the buyer confirms receipt or opens a dispute first, "+
+				"it will be completed automatically on %s and the payment added to your balance.",
+				transaction.ID, delivered.DeliveredAt.Format("2 January 2006"), completeOn),
+		)
+		// Without the notice the buyer can't be held to it, so give up the
+		// claim and try again on the next run.
+		if err != nil {
+			if err := db.Model(&model.Transaction{}).Where("id = ?", transaction.ID).Update("completion_notice_at", nil).Error; err != nil {
+				return reminded, err
+			}
+			continue
+		}
+		reminded++
+	}
+	return reminded, nil
+}
+
+// AutoComplete finishes the transactions delivered more than after before
+// now that the buyer has neither confirmed nor disputed, once they were
+// announced at least notice ago. They are settled like a confirmed receipt:
+// the tailor is paid and the buyer earns their points. It returns how many
+// were finished.
+func AutoComplete(now time.Time, after, notice time.Duration) (int, error) {
+	db := database.GetInstance()
+
+	announced := db.Where("transactions.completion_notice_at IS NOT NULL AND transactions.completion_notice_at <= ?", now.Add(-notice))
+	deliveries, err := deliveredBefore(announced, now.Add(-after))
+	if err != nil {
+		return 0, err
+	}
+
+	note := fmt.Sprintf("Not confirmed or disputed within %d days of delivery", int(after.Hours()/24))
+	completed := 0
+	for _, delivered := range deliveries {
+		var transaction model.Transaction
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Preload("Requests").First(&transaction, delivered.TransactionID).Error; err != nil {
+				return err
+			}
+			points, err := settlement.Points(tx, transaction)
+			if err != nil {
+				return err
+			}
+			return settlement.Finish(tx, transaction.ID, lifecycle.System, note, points)
+		})
+		// The buyer may have confirmed or disputed it since it was picked;
+		// the lifecycle refuses the change then.
+		if errors.Is(err, lifecycle.ErrInvalidTransition) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to complete delivered transaction %d: %v", delivered.TransactionID, err)
+			continue
+		}
+
+		_ = notify(transaction,
+			fmt.Sprintf("Your order #%d has been completed", transaction.ID),
+			fmt.Sprintf("Order #%d was delivered on %s and has now been completed automatically, "+
+				"and the payment released to the tailor. Thank you for shopping with TailorTech.",
+				transaction.ID, delivered.DeliveredAt.Format("2 January 2006")),
+			fmt.Sprintf("Order #%d has been completed", transaction.ID),
+			fmt.Sprintf("Order #%d was delivered on %s and has now been completed automatically. "+
+				"The payment, less the platform fee, has been added to your balance.",
+				transaction.ID, delivered.DeliveredAt.Format("2 January 2006")),
+		)
+		completed++
+	}
+	return completed, nil
+}
+
+// notify emails the transaction's buyer and tailor. A failed email is logged
+// and doesn't stop the job; the error reports whether the buyer's failed.
+func notify(transaction model.Transaction, userSubject, userBody, tailorSubject, tailorBody string) error {
+	db := database.GetInstance()
+
+	var err error
+	var user model.User
+	if db.Limit(1).Find(&user, transaction.UserID).RowsAffected > 0 {
+		err = send(user.Email, userSubject, fmt.Sprintf("Hi %s,\n\n%s\n", user.Name, userBody))
+	}
+
+	var tailor model.Tailor
+	if db.Limit(1).Find(&tailor, transaction.TailorID).RowsAffected > 0 {
+		send(tailor.Email, tailorSubject, fmt.Sprintf("Hi %s,\n\n%s\n", tailor.Name, tailorBody))
+	}
+	return err
+}
+
+func send(to, subject, body string) error {
+	if to == "" {
+		return nil
+	}
+	err := mail.Get().Send(mail.Message{To: to, Subject: subject, Body: body})
+	if err != nil {
+		log.Printf("Failed to email %s: %v", to, err)
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"main/config"
+	"main/database"
+	"main/escrow"
+	"main/lifecycle"
+	"main/mail"
+	model "main/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testSecret = "0123456789abcdef0123456789abcdef"
+
+	day    = 24 * time.Hour
+	after  = 7 * day
+	notice = 2 * day
+)
+
+func setUp(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "jobs.db") + "?_txlock=immediate&_pragma=busy_timeout(10000)"
+	t.Setenv("TAILORTECH_DATABASE_DSN", dsn)
+	t.Setenv("TAILORTECH_JWT_SECRET", testSecret)
+	t.Setenv("TAILORTECH_PAYMENT_WEBHOOK_SECRET", testSecret)
+	t.Setenv("TAILORTECH_QUOTE_SECRET", testSecret)
+	t.Setenv("TAILORTECH_MAIL_OUTBOX_DIR", t.TempDir())
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mail.Init(cfg.Mail); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Use(db)
+	if err := db.AutoMigrate(&model.Outfit{}, &model.Request{}, &model.TailorPrice{}); err != nil {
+		t.Fatal(err)
+	}
+	model.Migrate()
+	return db
+}
+
+// delivered stores a paid transaction, held in escrow, that was delivered
+// at each of the given times.
+func delivered(t *testing.T, db *gorm.DB, at ...time.Time) model.Transaction {
+	t.Helper()
+
+	user := model.User{Name: "Buyer", Email: "buyer@example.com", Money: 200}
+	tailor := model.Tailor{Name: "Tailor", Email: "tailor@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&tailor).Error; err != nil {
+		t.Fatal(err)
+	}
+	transaction := model.Transaction{TransactionDate: at[0].Add(-day), UserID: user.ID, TailorID: tailor.ID, Status: model.StatusDelivered, TotalPrice: 200}
+	if err := db.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := escrow.Hold(db, transaction); err != nil {
+		t.Fatal(err)
+	}
+	for _, deliveredAt := range at {
+		change := model.StatusChange{TransactionID: transaction.ID, FromStatus: model.StatusShipped, ToStatus: model.StatusDelivered, Actor: "tailor", CreatedAt: deliveredAt}
+		if err := db.Create(&change).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return transaction
+}
+
+func TestAutoCompleteWaitsForTheNotice(t *testing.T) {
+	db := setUp(t)
+	now := time.Now()
+	// Overdue already, as after the server was down.
+	transaction := delivered(t, db, now.Add(-after-day))
+
+	if completed, err := AutoComplete(now, after, notice); err != nil || completed != 0 {
+		t.Fatalf("AutoComplete() before any notice = %d, %v, want 0", completed, err)
+	}
+
+	if reminded, err := RemindAutoComplete(now, after, notice); err != nil || reminded != 1 {
+		t.Fatalf("RemindAutoComplete() = %d, %v, want 1", reminded, err)
+	}
+	if reminded, err := RemindAutoComplete(now, after, notice); err != nil || reminded != 0 {
+		t.Fatalf("second RemindAutoComplete() = %d, %v, want 0", reminded, err)
+	}
+
+	if completed, err := AutoComplete(now.Add(notice-time.Minute), after, notice); err != nil || completed != 0 {
+		t.Fatalf("AutoComplete() before the notice ran out = %d, %v, want 0", completed, err)
+	}
+	if completed, err := AutoComplete(now.Add(notice), after, notice); err != nil || completed != 1 {
+		t.Fatalf("AutoComplete() once the notice ran out = %d, %v, want 1", completed, err)
+	}
+
+	db.First(&transaction, transaction.ID)
+	if transaction.Status != model.StatusFinished {
+		t.Errorf("status = %s, want %s", transaction.Status, model.StatusFinished)
+	}
+}
+
+func TestAutoCompleteCountsFromTheLatestDelivery(t *testing.T) {
+	db := setUp(t)
+	now := time.Now()
+	transaction := delivered(t, db, now.Add(-after-3*day), now.Add(-after-2*day), now.Add(-day))
+
+	deliveries, err := deliveredBefore(db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].TransactionID != transaction.ID || !deliveries[0].DeliveredAt.Equal(now.Add(-day)) {
+		t.Fatalf("deliveredBefore() = %+v, want the one delivery on %v", deliveries, now.Add(-day))
+	}
+
+	if reminded, err := RemindAutoComplete(now, after, notice); err != nil || reminded != 0 {
+		t.Errorf("RemindAutoComplete() a day after the last delivery = %d, %v, want 0", reminded, err)
+	}
+	if reminded, err := RemindAutoComplete(now.Add(after-notice), after, notice); err != nil || reminded != 1 {
+		t.Errorf("RemindAutoComplete() notice before the last delivery is due = %d, %v, want 1", reminded, err)
+	}
+}
+
+func TestLeavingDeliveredClearsTheNotice(t *testing.T) {
+	db := setUp(t)
+	now := time.Now()
+	transaction := delivered(t, db, now.Add(-after))
+
+	if reminded, err := RemindAutoComplete(now, after, notice); err != nil || reminded != 1 {
+		t.Fatalf("RemindAutoComplete() = %d, %v, want 1", reminded, err)
+	}
+
+	buyer := lifecycle.By{Actor: lifecycle.ActorUser, ID: transaction.UserID}
+	if _, err := lifecycle.Apply(db, transaction.ID, model.StatusDisputed, buyer, "Torn seam"); err != nil {
+		t.Fatal(err)
+	}
+	db.First(&transaction, transaction.ID)
+	if transaction.CompletionNoticeAt != nil {
+		t.Errorf("CompletionNoticeAt = %v after the dispute, want nil", transaction.CompletionNoticeAt)
+	}
+}
@@ -0,0 +1,45 @@
+// Package jobs runs the work the server does on its own schedule rather
+// than in answer to a request.
+package jobs
+
+import (
+	"log"
+	"main/config"
+	"time"
+)
+
+// interval is how often each job looks for work.
+const interval = time.Minute
+
+// Start runs the background jobs until the process exits.
+func Start(cfg config.OrdersConfig) {
+	unpaidTimeout := time.Duration(cfg.UnpaidTimeoutMinutes) * time.Minute
+	go every(interval, "cancel unpaid transactions", func(now time.Time) (int, error) {
+		return CancelUnpaid(now, unpaidTimeout)
+	})
+
+	completeAfter := time.Duration(cfg.AutoCompleteDays) * 24 * time.Hour
+	completeNotice := time.Duration(cfg.AutoCompleteNoticeDays) * 24 * time.Hour
+	go every(interval, "complete delivered transactions", func(now time.Time) (int, error) {
+		return AutoComplete(now, completeAfter, completeNotice)
+	})
+	go every(interval, "announce auto-completion", func(now time.Time) (int, error) {
+		return RemindAutoComplete(now, completeAfter, completeNotice)
+	})
+}
+
+// every runs job now and then once per period, logging what it did.
+func every(period time.Duration, name string, job func(now time.Time) (int, error)) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for now := time.Now(); ; now = <-ticker.C {
+		done, err := job(now)
+		if err != nil {
+			log.Printf("Job %q failed: %v", name, err)
+		}
+		if done > 0 {
+			log.Printf("Job %q: %d done", name, done)
+		}
+	}
+}
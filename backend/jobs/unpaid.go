@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"main/database"
+	"main/lifecycle"
+	model "main/models"
+	"main/settlement"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CancelUnpaid cancels the transactions placed more than timeout before now
+// and still waiting for payment, putting their products back on sale. Transactions a
+// checkout top-up is still on its way for are left to it. It returns how
+// many were cancelled.
+func CancelUnpaid(now time.Time, timeout time.Duration) (int, error) {
+	db := database.GetInstance()
+
+	awaitingTopUp := db.Table("top_up_transactions").
+		Joins("JOIN top_ups ON top_ups.id = top_up_transactions.top_up_id").
+		Where("top_ups.status = ? AND (top_ups.expires_at IS NULL OR top_ups.expires_at > ?)", model.TopUpPending, now).
+		Select("top_up_transactions.transaction_id")
+
+	var ids []uint
+	if err := db.Model(&model.Transaction{}).
+		Where("status = ? AND transaction_date < ?", model.StatusPendingPayment, now.Add(-timeout)).
+		Where("id NOT IN (?)", awaitingTopUp).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	note := fmt.Sprintf("Not paid within %d minutes", int(timeout.Minutes()))
+	cancelled := 0
+	for _, id := range ids {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return settlement.Cancel(tx, id, lifecycle.System, note)
+		})
+		// The buyer may have paid or cancelled since the transaction was
+		// picked; the lifecycle refuses the change then.
+		if errors.Is(err, lifecycle.ErrInvalidTransition) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to cancel unpaid transaction %d: %v", id, err)
+			continue
+		}
+		cancelled++
+	}
+	return cancelled, nil
+}
@@ -0,0 +1,251 @@
+// Package ledger records every change to a wallet as a balanced journal
+// entry. User.Money and Tailor.Money are caches of the wallet accounts'
+// balances and are only written from here.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	model "main/models"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Account identifies a ledger account without loading it.
+type Account struct {
+	Kind    string
+	OwnerID uint
+}
+
+var (
+	Escrow       = Account{Kind: model.AccountEscrow}
+	PlatformFees = Account{Kind: model.AccountPlatformFees}
+	External     = Account{Kind: model.AccountExternal}
+	Adjustments  = Account{Kind: model.AccountAdjustments}
+
+	PendingPayouts = Account{Kind: model.AccountPendingPayouts}
+)
+
+func UserWallet(id uint) Account {
+	return Account{Kind: model.AccountUserWallet, OwnerID: id}
+}
+
+func TailorWallet(id uint) Account {
+	return Account{Kind: model.AccountTailorWallet, OwnerID: id}
+}
+
+// Leg is one side of an entry: Amount moves into Account, or out of it
+// when negative.
+type Leg struct {
+	Account Account
+	Amount  int
+}
+
+var (
+	ErrUnbalanced        = errors.New("ledger: entry does not balance")
+	ErrInsufficientFunds = errors.New("ledger: insufficient funds")
+)
+
+// Entry describes a movement to be posted.
+type Entry struct {
+	Kind          string
+	Description   string
+	TransactionID *uint
+	Legs          []Leg
+}
+
+// Transfer posts a single movement of amount from one account to another.
+// The entry's postings come back in that order: source, then destination.
+func Transfer(tx *gorm.DB, kind, description string, from, to Account, amount int) (model.JournalEntry, error) {
+	return Post(tx, Entry{
+		Kind:        kind,
+		Description: description,
+		Legs:        []Leg{{Account: from, Amount: -amount}, {Account: to, Amount: amount}},
+	})
+}
+
+// Post writes the entry and its postings and moves the account balances,
+// locking each account row until tx ends. Call it inside a transaction, and
+// roll back on error: ErrInsufficientFunds means a wallet would have gone
+// negative.
+func Post(tx *gorm.DB, entry Entry) (model.JournalEntry, error) {
+	sum := 0
+	for _, leg := range entry.Legs {
+		sum += leg.Amount
+	}
+	if sum != 0 || len(entry.Legs) < 2 {
+		return model.JournalEntry{}, ErrUnbalanced
+	}
+
+	journalEntry := model.JournalEntry{
+		Kind:          entry.Kind,
+		Description:   entry.Description,
+		TransactionID: entry.TransactionID,
+	}
+	if err := tx.Create(&journalEntry).Error; err != nil {
+		return model.JournalEntry{}, err
+	}
+
+	// Lock in a fixed order so two entries touching the same accounts can't
+	// deadlock each other.
+	accounts := make([]Account, 0, len(entry.Legs))
+	for _, leg := range entry.Legs {
+		accounts = append(accounts, leg.Account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Kind != accounts[j].Kind {
+			return accounts[i].Kind < accounts[j].Kind
+		}
+		return accounts[i].OwnerID < accounts[j].OwnerID
+	})
+
+	// Opening an account can post to External, so open them all before
+	// holding any balance in memory.
+	for _, account := range accounts {
+		if _, err := GetAccount(tx, account); err != nil {
+			return model.JournalEntry{}, err
+		}
+	}
+
+	locked := make(map[Account]*model.LedgerAccount, len(accounts))
+	for _, account := range accounts {
+		if _, ok := locked[account]; ok {
+			continue
+		}
+		ledgerAccount, err := lockAccount(tx, account)
+		if err != nil {
+			return model.JournalEntry{}, err
+		}
+		locked[account] = &ledgerAccount
+	}
+
+	for _, leg := range entry.Legs {
+		account := locked[leg.Account]
+		if isWallet(account.Kind) && account.Balance+leg.Amount < 0 {
+			return model.JournalEntry{}, ErrInsufficientFunds
+		}
+
+		posting, err := writePosting(tx, journalEntry.ID, account, leg.Amount)
+		if err != nil {
+			return model.JournalEntry{}, err
+		}
+		journalEntry.Postings = append(journalEntry.Postings, posting)
+	}
+
+	return journalEntry, nil
+}
+
+// GetAccount returns the ledger account, opening it if it doesn't exist yet.
+func GetAccount(tx *gorm.DB, account Account) (model.LedgerAccount, error) {
+	var ledgerAccount model.LedgerAccount
+	if tx.Where("kind = ? AND owner_id = ?", account.Kind, account.OwnerID).Limit(1).Find(&ledgerAccount).RowsAffected != 0 {
+		return ledgerAccount, nil
+	}
+	return openAccount(tx, account)
+}
+
+func lockAccount(tx *gorm.DB, account Account) (model.LedgerAccount, error) {
+	var ledgerAccount model.LedgerAccount
+	if tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("kind = ? AND owner_id = ?", account.Kind, account.OwnerID).Limit(1).Find(&ledgerAccount).RowsAffected != 0 {
+		return ledgerAccount, nil
+	}
+	return openAccount(tx, account)
+}
+
+func isWallet(kind string) bool {
+	return kind == model.AccountUserWallet || kind == model.AccountTailorWallet
+}
+
+// openAccount creates the account. Wallets that already hold money from
+// before the ledger existed get an opening balance entry against External,
+// so their history still adds up. When another transaction opens the same
+// account concurrently, the unique index makes this wait for it and then
+// return its account.
+func openAccount(tx *gorm.DB, account Account) (model.LedgerAccount, error) {
+	ledgerAccount := model.LedgerAccount{Kind: account.Kind, OwnerID: account.OwnerID}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&ledgerAccount)
+	if result.Error != nil {
+		return model.LedgerAccount{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("kind = ? AND owner_id = ?", account.Kind, account.OwnerID).First(&ledgerAccount).Error
+		return ledgerAccount, err
+	}
+
+	opening, err := cachedBalance(tx, account)
+	if err != nil || opening == 0 {
+		return ledgerAccount, err
+	}
+
+	journalEntry := model.JournalEntry{Kind: model.EntryOpeningBalance, Description: "Balance carried over from before the ledger"}
+	if err := tx.Create(&journalEntry).Error; err != nil {
+		return model.LedgerAccount{}, err
+	}
+
+	external, err := lockAccount(tx, External)
+	if err != nil {
+		return model.LedgerAccount{}, err
+	}
+	if _, err := writePosting(tx, journalEntry.ID, &external, -opening); err != nil {
+		return model.LedgerAccount{}, err
+	}
+	if _, err := writePosting(tx, journalEntry.ID, &ledgerAccount, opening); err != nil {
+		return model.LedgerAccount{}, err
+	}
+
+	return ledgerAccount, nil
+}
+
+// writePosting moves amount into the locked account and records it under
+// the journal entry.
+func writePosting(tx *gorm.DB, journalEntryID uint, account *model.LedgerAccount, amount int) (model.Posting, error) {
+	account.Balance += amount
+	if err := tx.Model(account).Update("balance", account.Balance).Error; err != nil {
+		return model.Posting{}, err
+	}
+	if err := syncWallet(tx, *account); err != nil {
+		return model.Posting{}, err
+	}
+
+	posting := model.Posting{
+		JournalEntryID: journalEntryID,
+		AccountID:      account.ID,
+		Amount:         amount,
+		BalanceAfter:   account.Balance,
+	}
+	return posting, tx.Create(&posting).Error
+}
+
+// cachedBalance reads the wallet balance stored on the user or tailor.
+func cachedBalance(tx *gorm.DB, account Account) (int, error) {
+	var balances []int
+	var err error
+	switch account.Kind {
+	case model.AccountUserWallet:
+		err = tx.Model(&model.User{}).Where("id = ?", account.OwnerID).Pluck("money", &balances).Error
+	case model.AccountTailorWallet:
+		err = tx.Model(&model.Tailor{}).Where("id = ?", account.OwnerID).Pluck("money", &balances).Error
+	default:
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(balances) == 0 {
+		return 0, fmt.Errorf("ledger: no owner for %s account %d: %w", account.Kind, account.OwnerID, gorm.ErrRecordNotFound)
+	}
+	return balances[0], nil
+}
+
+// syncWallet copies a wallet account's balance to its owner's Money.
+func syncWallet(tx *gorm.DB, account model.LedgerAccount) error {
+	switch account.Kind {
+	case model.AccountUserWallet:
+		return tx.Model(&model.User{}).Where("id = ?", account.OwnerID).Update("money", account.Balance).Error
+	case model.AccountTailorWallet:
+		return tx.Model(&model.Tailor{}).Where("id = ?", account.OwnerID).Update("money", account.Balance).Error
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package ledger
+
+import (
+	"errors"
+	model "main/models"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a throwaway SQLite database. SQLite has no row locks, so
+// transactions are started IMMEDIATE and run one at a time. Tests on it
+// check the ledger's arithmetic and overdraft rules, not its locking; that
+// is covered against MySQL by the mysql-tagged tests in controller.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "ledger.db") + "?_txlock=immediate&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&model.User{}, &model.Tailor{}, &model.LedgerAccount{}, &model.JournalEntry{}, &model.Posting{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestConcurrentTopUpsAndWithdrawals(t *testing.T) {
+	db := newTestDB(t)
+
+	// The wallet predates the ledger, so its first movement also posts the
+	// opening balance.
+	user := model.User{Name: "Buyer", Email: "buyer@example.com", Money: 100}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	wallet := UserWallet(user.ID)
+
+	const workers = 40
+	const topUp = 10
+	const withdrawal = 25
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	withdrawn := 0
+	errs := make(chan error, 2*workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- db.Transaction(func(tx *gorm.DB) error {
+				_, err := Transfer(tx, model.EntryTopUp, "top-up", External, wallet, topUp)
+				return err
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, err := Transfer(tx, model.EntryWithdrawal, "withdrawal", wallet, External, withdrawal)
+				return err
+			})
+			if errors.Is(err, ErrInsufficientFunds) {
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				withdrawn++
+				mu.Unlock()
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := 100 + workers*topUp - withdrawn*withdrawal
+
+	if err := db.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Money != want {
+		t.Errorf("User.Money = %d, want %d", user.Money, want)
+	}
+
+	account, err := GetAccount(db, wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != want {
+		t.Errorf("wallet balance = %d, want %d", account.Balance, want)
+	}
+
+	var negative int64
+	db.Model(&model.Posting{}).Where("account_id = ? AND balance_after < 0", account.ID).Count(&negative)
+	if negative != 0 {
+		t.Errorf("wallet went negative %d times", negative)
+	}
+
+	discrepancies, unbalanced, err := Reconcile(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 || len(unbalanced) != 0 {
+		t.Errorf("Reconcile() = %+v, %v, want no discrepancies", discrepancies, unbalanced)
+	}
+}
+
+func TestPostRejectsUnbalancedEntries(t *testing.T) {
+	db := newTestDB(t)
+
+	_, err := Post(db, Entry{
+		Kind: model.EntryAdjustment,
+		Legs: []Leg{{Account: Escrow, Amount: -10}, {Account: PlatformFees, Amount: 9}},
+	})
+	if !errors.Is(err, ErrUnbalanced) {
+		t.Fatalf("Post() error = %v, want ErrUnbalanced", err)
+	}
+}
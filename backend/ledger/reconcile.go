@@ -0,0 +1,64 @@
+package ledger
+
+import (
+	model "main/models"
+
+	"gorm.io/gorm"
+)
+
+// Discrepancy is an account whose stored balance disagrees with Expected,
+// the figure derived from Source.
+type Discrepancy struct {
+	AccountID uint
+	Kind      string
+	OwnerID   uint
+	Balance   int
+	Expected  int
+	Source    string
+}
+
+// Reconcile checks every account balance against the sum of its postings,
+// every wallet against its owner's cached Money, and every journal entry
+// against zero. It returns whatever doesn't add up.
+func Reconcile(tx *gorm.DB) ([]Discrepancy, []uint, error) {
+	var discrepancies []Discrepancy
+
+	var posted []Discrepancy
+	if err := tx.Table("ledger_accounts").
+		Select("ledger_accounts.id AS account_id, ledger_accounts.kind, ledger_accounts.owner_id, ledger_accounts.balance, COALESCE(SUM(postings.amount), 0) AS expected").
+		Joins("LEFT JOIN postings ON postings.account_id = ledger_accounts.id").
+		Group("ledger_accounts.id, ledger_accounts.kind, ledger_accounts.owner_id, ledger_accounts.balance").
+		Having("ledger_accounts.balance <> COALESCE(SUM(postings.amount), 0)").
+		Scan(&posted).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, discrepancy := range posted {
+		discrepancy.Source = "postings"
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	for kind, table := range map[string]string{model.AccountUserWallet: "users", model.AccountTailorWallet: "tailors"} {
+		var cached []Discrepancy
+		if err := tx.Table("ledger_accounts").
+			Select("ledger_accounts.id AS account_id, ledger_accounts.kind, ledger_accounts.owner_id, ledger_accounts.balance, "+table+".money AS expected").
+			Joins("JOIN "+table+" ON "+table+".id = ledger_accounts.owner_id").
+			Where("ledger_accounts.kind = ? AND "+table+".money <> ledger_accounts.balance", kind).
+			Scan(&cached).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, discrepancy := range cached {
+			discrepancy.Source = table + ".money"
+			discrepancies = append(discrepancies, discrepancy)
+		}
+	}
+
+	var unbalanced []uint
+	if err := tx.Model(&model.Posting{}).
+		Group("journal_entry_id").
+		Having("SUM(amount) <> 0").
+		Pluck("journal_entry_id", &unbalanced).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return discrepancies, unbalanced, nil
+}
@@ -0,0 +1,177 @@
+// Package lifecycle defines the states an order or custom request moves
+// through, and who may move it from one to the next. Every status change
+// goes through Apply, which records it in the transaction's history.
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	model "main/models"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Actor is who asks for a status change.
+type Actor string
+
+const (
+	ActorUser   Actor = "user"
+	ActorTailor Actor = "tailor"
+	ActorAdmin  Actor = "admin"
+	// ActorSystem is the server acting on its own, as when a payment
+	// arrives or a scheduled job runs.
+	ActorSystem Actor = "system"
+)
+
+// By identifies who made a change. ID is the user, tailor or admin, and
+// zero for the system.
+type By struct {
+	Actor Actor
+	ID    uint
+}
+
+// System makes the changes the server makes on its own.
+var System = By{Actor: ActorSystem}
+
+var (
+	ErrUnknownStatus     = errors.New("lifecycle: unknown status")
+	ErrInvalidTransition = errors.New("lifecycle: invalid transition")
+	ErrNotFound          = errors.New("lifecycle: transaction not found")
+)
+
+// TransitionError is an ErrInvalidTransition naming the move that was
+// refused.
+type TransitionError struct {
+	From, To string
+	Actor    Actor
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("lifecycle: %s can't move a transaction from %s to %s", e.Actor, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+type edge struct{ from, to string }
+
+// transitions lists every allowed status change and who may make it.
+var transitions = map[edge][]Actor{
+	{model.StatusPendingPayment, model.StatusPaid}:      {ActorSystem},
+	{model.StatusPendingPayment, model.StatusCancelled}: {ActorUser, ActorAdmin, ActorSystem},
+
+	// The buyer may change their mind until the tailor accepts; the tailor
+	// may back out until the order ships.
+	{model.StatusPaid, model.StatusAccepted}:  {ActorTailor},
+	{model.StatusPaid, model.StatusCancelled}: {ActorUser, ActorTailor, ActorAdmin},
+
+	{model.StatusAccepted, model.StatusInProgress}: {ActorTailor},
+	// Ready-made products can be shipped as soon as they are accepted.
+	{model.StatusAccepted, model.StatusShipped}:   {ActorTailor},
+	{model.StatusAccepted, model.StatusCancelled}: {ActorTailor, ActorAdmin},
+
+	{model.StatusInProgress, model.StatusShipped}:   {ActorTailor},
+	{model.StatusInProgress, model.StatusCancelled}: {ActorTailor, ActorAdmin},
+
+	{model.StatusShipped, model.StatusDelivered}: {ActorTailor, ActorSystem},
+	// Confirming receipt before delivery is recorded is fine by the buyer.
+	{model.StatusShipped, model.StatusFinished}: {ActorUser},
+	{model.StatusShipped, model.StatusDisputed}: {ActorUser},
+
+	{model.StatusDelivered, model.StatusFinished}: {ActorUser, ActorSystem},
+	{model.StatusDelivered, model.StatusDisputed}: {ActorUser},
+
+	// Admins settle disputes by paying the tailor or refunding the buyer.
+	{model.StatusDisputed, model.StatusFinished}:  {ActorAdmin},
+	{model.StatusDisputed, model.StatusCancelled}: {ActorAdmin},
+}
+
+// Statuses lists every status, in lifecycle order.
+var Statuses = []string{
+	model.StatusPendingPayment,
+	model.StatusPaid,
+	model.StatusAccepted,
+	model.StatusInProgress,
+	model.StatusShipped,
+	model.StatusDelivered,
+	model.StatusFinished,
+	model.StatusCancelled,
+	model.StatusDisputed,
+}
+
+// Normalize returns the status spelled the way it is stored, matching
+// case-insensitively.
+func Normalize(status string) (string, error) {
+	for _, known := range Statuses {
+		if strings.EqualFold(status, known) {
+			return known, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownStatus, status)
+}
+
+// Check reports whether the actor may move a transaction from one status to
+// the other.
+func Check(from, to string, actor Actor) error {
+	for _, allowed := range transitions[edge{from, to}] {
+		if allowed == actor {
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: to, Actor: actor}
+}
+
+// Next lists the statuses the actor may move a transaction on to.
+func Next(from string, actor Actor) []string {
+	var next []string
+	for _, to := range Statuses {
+		if Check(from, to, actor) == nil {
+			next = append(next, to)
+		}
+	}
+	return next
+}
+
+// Start records that a new transaction was placed, opening its history.
+func Start(tx *gorm.DB, transaction model.Transaction, by By) error {
+	return record(tx, transaction.ID, "", transaction.Status, by, "")
+}
+
+// Apply locks the transaction, checks the actor may move it to the new
+// status, and makes and records the change. It returns the transaction as
+// it was before, so callers can act on the status it left.
+func Apply(tx *gorm.DB, transactionID uint, to string, by By, note string) (model.Transaction, error) {
+	var transaction model.Transaction
+	if tx.Clauses(clause.Locking{Strength: "UPDATE"}).Limit(1).Find(&transaction, transactionID).RowsAffected == 0 {
+		return model.Transaction{}, ErrNotFound
+	}
+
+	if err := Check(transaction.Status, to, by.Actor); err != nil {
+		return transaction, err
+	}
+
+	changes := map[string]interface{}{"status": to}
+	// The auto-completion notice is for this delivery; if it's delivered
+	// again the buyer is owed a new one.
+	if transaction.Status == model.StatusDelivered {
+		changes["completion_notice_at"] = nil
+	}
+	if err := tx.Model(&model.Transaction{}).Where("id = ?", transaction.ID).Updates(changes).Error; err != nil {
+		return transaction, err
+	}
+	return transaction, record(tx, transaction.ID, transaction.Status, to, by, note)
+}
+
+func record(tx *gorm.DB, transactionID uint, from, to string, by By, note string) error {
+	return tx.Create(&model.StatusChange{
+		TransactionID: transactionID,
+		FromStatus:    from,
+		ToStatus:      to,
+		Actor:         string(by.Actor),
+		ActorID:       by.ID,
+		Note:          note,
+	}).Error
+}
@@ -0,0 +1,161 @@
+package lifecycle
+
+import (
+	"errors"
+	model "main/models"
+	"reflect"
+	"testing"
+)
+
+var actors = []Actor{ActorUser, ActorTailor, ActorAdmin, ActorSystem}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		from, to string
+		actor    Actor
+		allowed  bool
+	}{
+		{model.StatusPendingPayment, model.StatusPaid, ActorSystem, true},
+		{model.StatusPendingPayment, model.StatusPaid, ActorUser, false},
+		{model.StatusPendingPayment, model.StatusPaid, ActorAdmin, false},
+		{model.StatusPendingPayment, model.StatusCancelled, ActorUser, true},
+		{model.StatusPendingPayment, model.StatusCancelled, ActorAdmin, true},
+		{model.StatusPendingPayment, model.StatusCancelled, ActorSystem, true},
+		{model.StatusPendingPayment, model.StatusCancelled, ActorTailor, false},
+		{model.StatusPendingPayment, model.StatusAccepted, ActorTailor, false},
+
+		{model.StatusPaid, model.StatusAccepted, ActorTailor, true},
+		{model.StatusPaid, model.StatusAccepted, ActorUser, false},
+		{model.StatusPaid, model.StatusCancelled, ActorUser, true},
+		{model.StatusPaid, model.StatusCancelled, ActorTailor, true},
+		{model.StatusPaid, model.StatusCancelled, ActorAdmin, true},
+		{model.StatusPaid, model.StatusCancelled, ActorSystem, false},
+		{model.StatusPaid, model.StatusShipped, ActorTailor, false},
+		{model.StatusPaid, model.StatusPendingPayment, ActorAdmin, false},
+
+		{model.StatusAccepted, model.StatusInProgress, ActorTailor, true},
+		{model.StatusAccepted, model.StatusShipped, ActorTailor, true},
+		{model.StatusAccepted, model.StatusCancelled, ActorTailor, true},
+		{model.StatusAccepted, model.StatusCancelled, ActorAdmin, true},
+		{model.StatusAccepted, model.StatusCancelled, ActorUser, false},
+		{model.StatusAccepted, model.StatusPaid, ActorTailor, false},
+
+		{model.StatusInProgress, model.StatusShipped, ActorTailor, true},
+		{model.StatusInProgress, model.StatusShipped, ActorAdmin, false},
+		{model.StatusInProgress, model.StatusCancelled, ActorTailor, true},
+		{model.StatusInProgress, model.StatusCancelled, ActorAdmin, true},
+		{model.StatusInProgress, model.StatusCancelled, ActorUser, false},
+		{model.StatusInProgress, model.StatusDelivered, ActorTailor, false},
+
+		{model.StatusShipped, model.StatusDelivered, ActorTailor, true},
+		{model.StatusShipped, model.StatusDelivered, ActorSystem, true},
+		{model.StatusShipped, model.StatusDelivered, ActorUser, false},
+		{model.StatusShipped, model.StatusFinished, ActorUser, true},
+		{model.StatusShipped, model.StatusFinished, ActorTailor, false},
+		{model.StatusShipped, model.StatusFinished, ActorSystem, false},
+		{model.StatusShipped, model.StatusDisputed, ActorUser, true},
+		{model.StatusShipped, model.StatusDisputed, ActorTailor, false},
+		{model.StatusShipped, model.StatusCancelled, ActorTailor, false},
+		{model.StatusShipped, model.StatusCancelled, ActorAdmin, false},
+
+		{model.StatusDelivered, model.StatusFinished, ActorUser, true},
+		{model.StatusDelivered, model.StatusFinished, ActorSystem, true},
+		{model.StatusDelivered, model.StatusFinished, ActorTailor, false},
+		{model.StatusDelivered, model.StatusDisputed, ActorUser, true},
+		{model.StatusDelivered, model.StatusDisputed, ActorSystem, false},
+		{model.StatusDelivered, model.StatusCancelled, ActorUser, false},
+
+		{model.StatusDisputed, model.StatusFinished, ActorAdmin, true},
+		{model.StatusDisputed, model.StatusFinished, ActorUser, false},
+		{model.StatusDisputed, model.StatusCancelled, ActorAdmin, true},
+		{model.StatusDisputed, model.StatusCancelled, ActorTailor, false},
+
+		{model.StatusFinished, model.StatusDisputed, ActorUser, false},
+		{model.StatusFinished, model.StatusCancelled, ActorAdmin, false},
+		{model.StatusCancelled, model.StatusPaid, ActorSystem, false},
+		{model.StatusCancelled, model.StatusPendingPayment, ActorAdmin, false},
+		{model.StatusPaid, model.StatusPaid, ActorSystem, false},
+		{"", model.StatusPaid, ActorSystem, false},
+		{model.StatusPaid, model.StatusAccepted, Actor("guest"), false},
+	}
+
+	for _, tt := range tests {
+		err := Check(tt.from, tt.to, tt.actor)
+		if tt.allowed && err != nil {
+			t.Errorf("Check(%q, %q, %s) = %v, want allowed", tt.from, tt.to, tt.actor, err)
+		}
+		if !tt.allowed {
+			var transition *TransitionError
+			if !errors.Is(err, ErrInvalidTransition) || !errors.As(err, &transition) ||
+				transition.From != tt.from || transition.To != tt.to || transition.Actor != tt.actor {
+				t.Errorf("Check(%q, %q, %s) = %v, want a TransitionError", tt.from, tt.to, tt.actor, err)
+			}
+		}
+	}
+
+	// Every move not listed as allowed above must be refused.
+	allowed := map[[3]string]bool{}
+	for _, tt := range tests {
+		if tt.allowed {
+			allowed[[3]string{tt.from, tt.to, string(tt.actor)}] = true
+		}
+	}
+	for _, from := range Statuses {
+		for _, to := range Statuses {
+			for _, actor := range actors {
+				if Check(from, to, actor) == nil && !allowed[[3]string{from, to, string(actor)}] {
+					t.Errorf("Check(%q, %q, %s) allowed a move not in the table", from, to, actor)
+				}
+			}
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		from  string
+		actor Actor
+		want  []string
+	}{
+		{model.StatusPaid, ActorTailor, []string{model.StatusAccepted, model.StatusCancelled}},
+		{model.StatusAccepted, ActorTailor, []string{model.StatusInProgress, model.StatusShipped, model.StatusCancelled}},
+		{model.StatusShipped, ActorUser, []string{model.StatusFinished, model.StatusDisputed}},
+		{model.StatusDelivered, ActorSystem, []string{model.StatusFinished}},
+		{model.StatusAccepted, ActorUser, nil},
+		{model.StatusFinished, ActorAdmin, nil},
+	}
+
+	for _, tt := range tests {
+		if got := Next(tt.from, tt.actor); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Next(%q, %s) = %v, want %v", tt.from, tt.actor, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{"Paid", model.StatusPaid},
+		{"paid", model.StatusPaid},
+		{"IN PROGRESS", model.StatusInProgress},
+		{model.StatusPendingPayment, model.StatusPendingPayment},
+		{"Lost", ""},
+		{" Paid", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := Normalize(tt.status)
+		if tt.want == "" {
+			if !errors.Is(err, ErrUnknownStatus) {
+				t.Errorf("Normalize(%q) error = %v, want ErrUnknownStatus", tt.status, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v, want %q", tt.status, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"fmt"
+	"main/config"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers outgoing email.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var mailer Mailer
+
+// Init builds the mailer selected by the config.
+func Init(cfg config.MailConfig) error {
+	switch cfg.Driver {
+	case "smtp":
+		mailer = &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}
+	case "outbox":
+		mailer = &OutboxMailer{Dir: cfg.OutboxDir, From: cfg.From}
+	default:
+		return fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+	return nil
+}
+
+func Get() Mailer {
+	if mailer == nil {
+		panic("mail: Get called before Init")
+	}
+	return mailer
+}
@@ -1,11 +1,22 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"main/auth"
+	"main/config"
 	"main/controller"
+	"main/database"
+	"main/escrow"
+	"main/gateway"
+	"main/idempotency"
+	"main/jobs"
+	"main/mail"
+	model "main/models"
 	"net"
 	"net/http"
+	"os"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -28,17 +39,72 @@ func getLocalIP() (string, error) {
 }
 
 func main() {
+	migrateTailorPasswords := flag.Bool("migrate-tailor-passwords", false, "hash plaintext tailor passwords and exit")
+	createAdminEmail := flag.String("create-admin", "", "create an admin with this email (password from TAILORTECH_ADMIN_PASSWORD) and exit")
+	createAdminName := flag.String("admin-name", "Administrator", "name for the admin created with -create-admin")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	if err := auth.LoadKeys(cfg); err != nil {
+		log.Fatalf("Error loading signing keys: %v", err)
+	}
+
+	if err := mail.Init(cfg.Mail); err != nil {
+		log.Fatalf("Error setting up mail delivery: %v", err)
+	}
+
+	if err := gateway.Init(cfg.PaymentGateway); err != nil {
+		log.Fatalf("Error setting up payment gateway: %v", err)
+	}
+
+	if err := database.Connect(cfg.DatabaseDSN); err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+
+	if *migrateTailorPasswords {
+		hashed, err := model.HashTailorPasswords()
+		if err != nil {
+			log.Fatalf("Error hashing tailor passwords: %v", err)
+		}
+		log.Printf("Hashed %d tailor passwords", hashed)
+		return
+	}
+
+	if *createAdminEmail != "" {
+		model.Migrate()
+		if err := controller.CreateAdmin(*createAdminName, *createAdminEmail, os.Getenv("TAILORTECH_ADMIN_PASSWORD")); err != nil {
+			log.Fatalf("Error creating admin: %v", err)
+		}
+		log.Printf("Created admin %s", *createAdminEmail)
+		return
+	}
+
 	ip, err := getLocalIP()
 	if err != nil {
 		log.Fatalf("Error fetching local IP address: %v", err)
 	}
 
+	model.Migrate()
+
+	if adopted, err := escrow.AdoptLegacy(database.GetInstance()); err != nil {
+		log.Fatalf("Error moving paid transactions into escrow: %v", err)
+	} else if adopted > 0 {
+		log.Printf("Moved %d transactions paid before escrow into escrow", adopted)
+	}
+
+	jobs.Start(cfg.Orders)
+
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:8081"},
+		AllowOrigins:     cfg.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", idempotency.Header},
+		ExposeHeaders:    []string{idempotency.ReplayedHeader},
 		AllowCredentials: true,
 	}))
 
@@ -46,50 +112,95 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"ip": ip})
 	})
 
-	r.POST("/register", controller.Register)
+	r.GET("/.well-known/jwks.json", controller.JWKSHandler)
+
+	register := r.Group("/register")
+	{
+		register.POST("", controller.Register)
+		register.POST("/tailor", controller.RegisterTailor)
+	}
 
 	login := r.Group("/login")
 	{
 		login.POST("/user", controller.LoginHandler)
 		login.POST("/tailor", controller.TailorLoginHandler)
+		login.POST("/admin", controller.AdminLoginHandler)
+		login.POST("/2fa", controller.TwoFactorLoginHandler)
+	}
+
+	password := r.Group("/password")
+	{
+		password.POST("/forgot", controller.ForgotPassword)
+		password.POST("/reset", controller.ResetPassword)
 	}
 
+	identify := auth.Identify()
+	anyone := auth.Authenticate()
+	userOnly := auth.Authenticate(auth.TypeUser)
+	verified := auth.RequireVerifiedEmail()
+	tailorOnly := auth.Authenticate(auth.TypeTailor)
+	buyerOrTailor := auth.Authenticate(auth.TypeUser, auth.TypeTailor)
+	adminOnly := auth.Authenticate(auth.TypeAdmin)
+	idempotent := idempotency.Middleware()
+
 	user := r.Group("/users")
 	{
-		user.GET("/:id", controller.GetUser)
-		user.GET("/get-all", controller.GetAllUsers)
-		user.POST("/update", controller.UpdateUser)
-		user.POST("/topup/:id", controller.TopUpHandler)
+		user.GET("/:id", userOnly, controller.GetUser)
+		user.POST("/update", userOnly, controller.UpdateUser)
+		user.POST("/topup/:id", userOnly, verified, idempotent, controller.TopUpHandler)
 	}
 
-	r.GET("/validate", controller.GetUserFromJWT)
+	r.GET("/validate", anyone, controller.GetUserFromJWT)
+
+	verifyEmail := r.Group("/verify-email")
+	{
+		verifyEmail.POST("", controller.VerifyEmail)
+		verifyEmail.POST("/resend", userOnly, controller.ResendVerificationEmail)
+	}
 	r.GET("/logout", controller.LogoutHandler)
+	r.POST("/auth/refresh", controller.RefreshHandler)
+
+	sessions := r.Group("/sessions", anyone)
+	{
+		sessions.GET("", controller.ListSessions)
+		sessions.DELETE("", controller.RevokeOtherSessions)
+		sessions.DELETE("/:id", controller.RevokeSession)
+	}
 
 	product := r.Group("/products")
 	{
 		product.GET("/get-all", controller.GetAllProduct)
 		product.GET("/get-tailor-active", controller.GetTailorProducts)
-		product.GET("/get-tailor-inactive", controller.GetInactiveTailorProducts)
-		product.DELETE("/delete/:id", controller.RemoveProduct)
-		product.PUT("/activate/:id", controller.ActivateProduct)
-		product.POST("/add", controller.AddProduct)
+		product.GET("/get-tailor-inactive", tailorOnly, controller.GetInactiveTailorProducts)
+		product.DELETE("/delete/:id", tailorOnly, controller.RemoveProduct)
+		product.PUT("/activate/:id", tailorOnly, controller.ActivateProduct)
+		product.POST("/add", tailorOnly, controller.AddProduct)
 	}
 
 	tailor := r.Group("/tailors")
 	{
-		tailor.GET("/:id", controller.GetTailor)
+		tailor.GET("/:id", identify, controller.GetTailor)
 		tailor.GET("/get-all", controller.GetAllTailor)
-		tailor.POST("/withdraw/:id", controller.WithdrawalHandler)
+		tailor.POST("/withdraw/:id", tailorOnly, idempotent, controller.WithdrawalHandler)
+	}
+
+	payouts := r.Group("/payouts", tailorOnly)
+	{
+		payouts.GET("/bank-accounts", controller.GetBankAccounts)
+		payouts.POST("/bank-accounts", controller.AddBankAccount)
+		payouts.DELETE("/bank-accounts/:id", controller.RemoveBankAccount)
+		payouts.GET("/withdrawals", controller.GetWithdrawals)
+		payouts.POST("/withdrawals/:id/cancel", controller.CancelWithdrawal)
 	}
 
-	coupon := r.Group("/coupons")
+	coupon := r.Group("/coupons", userOnly)
 	{
 		coupon.POST("/redeem", controller.RedeemCoupon)
 		coupon.GET("/code", controller.GetUserCoupons)
 		coupon.POST("/exchange", controller.ExchangePointsForCoupon)
 	}
 
-	measurements := r.Group("/measurements")
+	measurements := r.Group("/measurements", userOnly)
 	{
 		measurements.POST("/tops", controller.CreateTopMeasurement)
 		measurements.POST("/bottoms", controller.CreateBottomMeasurement)
@@ -98,14 +209,14 @@ func main() {
 		measurements.POST("/totebags", controller.CreateToteBagMeasurement)
 	}
 
-	carts := r.Group("/carts")
+	carts := r.Group("/carts", userOnly)
 	{
 		carts.POST("/add-to-cart", controller.AddToCart)
 		carts.GET("/get-cart/:id", controller.GetCart)
 		carts.DELETE("/remove", controller.RemoveFromCart)
 	}
 
-	wishlists := r.Group("/wishlists")
+	wishlists := r.Group("/wishlists", userOnly)
 	{
 		wishlists.POST("/add-to-wishlist", controller.AddToWishlist)
 		wishlists.GET("/:userID", controller.GetWishlist)
@@ -114,31 +225,86 @@ func main() {
 
 	requests := r.Group("/requests")
 	{
-		requests.POST("/create", controller.CreateUserRequest)
-		requests.GET("/get-user-request/:id", controller.GetUserRequest)
-		requests.GET("/get-tailor-request/:id", controller.GetTailorRequest)
-		requests.POST("/update-status", controller.UpdateRequestStatus)
-		requests.POST("/confirm-received", controller.HandleRequestReceived)
+		requests.POST("/create", userOnly, verified, idempotent, controller.CreateUserRequest)
+		requests.GET("/get-user-request/:id", userOnly, controller.GetUserRequest)
+		requests.GET("/get-tailor-request/:id", tailorOnly, controller.GetTailorRequest)
+		requests.POST("/update-status", tailorOnly, controller.UpdateRequestStatus)
+		requests.POST("/confirm-received", userOnly, controller.HandleRequestReceived)
+		requests.POST("/cancel", buyerOrTailor, controller.CancelTransaction)
+		requests.POST("/dispute", userOnly, controller.DisputeTransaction)
+		requests.GET("/:id/timeline", anyone, controller.GetTimeline)
+	}
+
+	r.POST("/checkout/quote", userOnly, controller.GetCheckoutQuote)
+	r.POST("/payment", userOnly, verified, idempotent, controller.ProcessPayment)
+
+	r.GET("/topups/:id", userOnly, controller.GetTopUp)
+	r.GET("/topups/:id/qris", userOnly, controller.GetTopUpQRIS)
+	r.POST("/gateway/webhooks/:provider", controller.GatewayWebhook)
+	if cfg.PaymentGateway.MockPayEndpoint {
+		log.Printf("Warning: anyone can mark mock payments paid at /gateway/mock/:reference/pay; never enable payment_gateway.mock_pay_endpoint in production")
+		r.POST("/gateway/mock/:reference/pay", controller.MockGatewayPay)
 	}
 
-	r.POST("/payment", controller.ProcessPayment)
+	r.GET("/wallet/statement", auth.Authenticate(auth.TypeUser, auth.TypeTailor), controller.GetWalletStatement)
 
 	orders := r.Group("/orders")
 	{
-		orders.POST("/create", controller.CreateProductOrder)
-		orders.GET("/get-user-order/:id", controller.GetUserOrder)
-		orders.GET("/get-tailor-order/:id", controller.GetTailorOrder)
-		orders.POST("/update-status", controller.UpdateOrderStatus)
-		orders.POST("/confirm-received", controller.HandleOrderReceived)
+		orders.POST("/create", userOnly, verified, idempotent, controller.CreateProductOrder)
+		orders.GET("/get-user-order/:id", userOnly, controller.GetUserOrder)
+		orders.GET("/get-tailor-order/:id", tailorOnly, controller.GetTailorOrder)
+		orders.POST("/update-status", tailorOnly, controller.UpdateOrderStatus)
+		orders.POST("/confirm-received", userOnly, controller.HandleOrderReceived)
+		orders.POST("/cancel", buyerOrTailor, controller.CancelTransaction)
+		orders.POST("/dispute", userOnly, controller.DisputeTransaction)
+		orders.GET("/:id/timeline", anyone, controller.GetTimeline)
+	}
+
+	twoFactor := r.Group("/2fa", auth.Authenticate(auth.TypeTailor, auth.TypeAdmin))
+	{
+		twoFactor.POST("/setup", controller.SetupTwoFactor)
+		twoFactor.POST("/enable", controller.EnableTwoFactor)
+		twoFactor.POST("/disable", controller.DisableTwoFactor)
+		twoFactor.POST("/recovery-codes", controller.RegenerateRecoveryCodes)
+	}
+
+	admin := r.Group("/admin", adminOnly)
+	{
+		admin.GET("/users", controller.AdminGetUsers)
+		admin.POST("/users/:id/suspend", controller.SuspendUser)
+		admin.POST("/users/:id/unsuspend", controller.UnsuspendUser)
+		admin.POST("/users/:id/adjust-balance", controller.AdjustUserBalance)
+		admin.GET("/tailors", controller.AdminGetTailors)
+		admin.POST("/tailors/:id/suspend", controller.SuspendTailor)
+		admin.POST("/tailors/:id/unsuspend", controller.UnsuspendTailor)
+		admin.POST("/tailors/:id/adjust-balance", controller.AdjustTailorBalance)
+		admin.POST("/tailors/:id/tier", controller.SetTailorTier)
+		admin.POST("/products/:id/deactivate", controller.AdminDeactivateProduct)
+		admin.GET("/transactions", controller.AdminGetTransactions)
+		admin.POST("/transactions/:id/resolve", controller.ResolveDispute)
+		admin.GET("/ledger/reconcile", controller.ReconcileLedger)
+		admin.GET("/fees", controller.AdminGetFeeRules)
+		admin.POST("/fees", controller.CreateFeeRule)
+		admin.POST("/fees/:id/end", controller.EndFeeRule)
+		admin.GET("/revenue", controller.GetRevenueReport)
+		admin.GET("/withdrawals", controller.AdminGetWithdrawals)
+		admin.GET("/withdrawals/export", controller.ExportWithdrawals)
+		admin.POST("/withdrawals/paid", controller.MarkWithdrawalsPaid)
+		admin.POST("/withdrawals/:id/approve", controller.ApproveWithdrawal)
+		admin.POST("/withdrawals/:id/reject", controller.RejectWithdrawal)
+		admin.GET("/login-locks", controller.GetLoginLocks)
+		admin.POST("/login-locks/unlock", controller.UnlockLogin)
 	}
 
-	r.POST("/submit-rating", controller.SubmitRating)
+	r.POST("/submit-rating", userOnly, controller.SubmitRating)
 
 	assistants := r.Group("/assistants")
 	{
 		assistants.GET("/available", controller.GetAvailableAssistants)
-		assistants.POST("/booking", controller.BookAssistant)
+		assistants.POST("/booking", userOnly, controller.BookAssistant)
 	}
 
-	r.Run(":8000")
+	if err := r.Run(":" + cfg.Port); err != nil {
+		log.Fatalf("Error starting server: %v", err)
+	}
 }
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type Admin struct {
+	gorm.Model
+	Name     string
+	Email    string `gorm:"size:191;uniqueIndex"`
+	Password string
+}
+
+func (admin *Admin) SetPassword(password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	admin.Password = string(hashedPassword)
+	return nil
+}
+
+// BalanceAdjustment records a manual change an admin made to a wallet.
+type BalanceAdjustment struct {
+	gorm.Model
+	AdminID      uint
+	AccountType  string
+	AccountID    uint
+	Amount       int
+	BalanceAfter int
+	Reason       string
+}
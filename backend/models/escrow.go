@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	HoldHeld     = "held"
+	HoldReleased = "released"
+	HoldRefunded = "refunded"
+)
+
+// EscrowHold is the payment for one transaction while it sits in escrow,
+// from checkout until it is released to the tailor or refunded.
+type EscrowHold struct {
+	gorm.Model
+	TransactionID uint `gorm:"uniqueIndex"`
+	UserID        uint `gorm:"index"`
+	TailorID      uint `gorm:"index"`
+	Amount        int
+	Status        string `gorm:"size:16"`
+	TailorAmount  int
+	FeeAmount     int
+	SettledAt     *time.Time
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of transaction a fee rule can apply to.
+const (
+	FeeKindProduct = "product"
+	FeeKindRequest = "request"
+)
+
+// Tailor tiers. Fee rules can charge tiers different rates.
+const (
+	TailorTierStandard = "standard"
+	TailorTierPremium  = "premium"
+)
+
+// FeeRule is one line of the platform fee schedule. An empty
+// OutfitCategory, Kind or TailorTier matches any. Rate is in basis points,
+// hundredths of a percent. Rules are not edited once in use; a new rate is
+// a new rule, and the old one is ended with EffectiveUntil.
+type FeeRule struct {
+	gorm.Model
+	OutfitCategory string `gorm:"size:32"`
+	Kind           string `gorm:"size:16"`
+	TailorTier     string `gorm:"size:16"`
+	Rate           int
+	EffectiveFrom  time.Time `gorm:"index"`
+	EffectiveUntil *time.Time
+	CreatedBy      uint
+}
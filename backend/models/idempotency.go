@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// IdempotencyKey remembers a request made with an Idempotency-Key header and
+// the response it got, so a retry can be answered without running it again.
+// Status is 0 while the first request is still being handled.
+type IdempotencyKey struct {
+	ID            uint   `gorm:"primarykey"`
+	PrincipalType int    `gorm:"uniqueIndex:idx_idempotency_key"`
+	PrincipalID   uint   `gorm:"uniqueIndex:idx_idempotency_key"`
+	Key           string `gorm:"column:idempotency_key;size:128;uniqueIndex:idx_idempotency_key"`
+	Fingerprint   string `gorm:"size:64"`
+	Status        int
+	ContentType   string
+	Body          []byte
+	CreatedAt     time.Time `gorm:"index"`
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ledger account kinds. Wallets have one account per owner; the system
+// accounts have OwnerID 0.
+const (
+	AccountUserWallet   = "user_wallet"
+	AccountTailorWallet = "tailor_wallet"
+	AccountEscrow       = "escrow"
+	AccountPlatformFees = "platform_fees"
+	// AccountExternal is the outside world: top-ups come from it and payouts
+	// go to it.
+	AccountExternal = "external"
+	// AccountAdjustments balances manual corrections made by admins.
+	AccountAdjustments = "adjustments"
+	// AccountPendingPayouts holds withdrawals that have been requested but
+	// not yet paid out.
+	AccountPendingPayouts = "pending_payouts"
+)
+
+// LedgerAccount is one balance in the double-entry ledger. Balance is the
+// sum of the account's postings, kept alongside them so it can be locked and
+// read cheaply.
+type LedgerAccount struct {
+	gorm.Model
+	Kind    string `gorm:"size:32;uniqueIndex:idx_ledger_account_owner"`
+	OwnerID uint   `gorm:"uniqueIndex:idx_ledger_account_owner"`
+	Balance int
+}
+
+// Journal entry kinds.
+const (
+	EntryOpeningBalance = "opening_balance"
+	EntryTopUp          = "top_up"
+	EntryPayment        = "payment"
+	EntrySettlement     = "settlement"
+	EntryRefund         = "refund"
+	EntryWithdrawal     = "withdrawal"
+	EntryAdjustment     = "adjustment"
+	// EntryWithdrawalHold sets a requested withdrawal aside and
+	// EntryWithdrawalRelease returns it when the request doesn't go ahead.
+	EntryWithdrawalHold    = "withdrawal_hold"
+	EntryWithdrawalRelease = "withdrawal_release"
+)
+
+// JournalEntry is one money movement. Its postings always sum to zero, and
+// neither is ever updated or deleted once written.
+type JournalEntry struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	Kind          string `gorm:"size:32;index"`
+	Description   string
+	TransactionID *uint `gorm:"index"`
+	Postings      []Posting
+}
+
+// Posting moves Amount into an account; negative amounts move money out.
+type Posting struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	JournalEntryID uint `gorm:"index"`
+	AccountID      uint `gorm:"index"`
+	Amount         int
+	BalanceAfter   int
+}
@@ -1,9 +1,18 @@
 package model
 
-import "main/database"
+import (
+	"main/database"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func Migrate() {
 	db := database.GetInstance()
+	// Accounts that existed before email verification was required are
+	// grandfathered in, so they aren't locked out of payments and orders.
+	// Checked first, as other tables migrate users along with them.
+	verificationIntroduced := db.Migrator().HasTable(&User{}) && !db.Migrator().HasColumn(&User{}, "EmailVerifiedAt")
 	// db.SetupJoinTable(&Tailor{}, "OutfitPrices", &TailorPrice{})
 	// db.SetupJoinTable(&User{}, "Promos", &UserPromo{})
 	// db.AutoMigrate(&User{})
@@ -22,5 +31,62 @@ func Migrate() {
 	// db.AutoMigrate(&TailorRating{})
 	// db.AutoMigrate(&Promo{})
 	db.AutoMigrate(&AssistantBooking{})
+	db.AutoMigrate(&Session{})
+	db.AutoMigrate(&UserToken{})
+	db.AutoMigrate(&User{})
+	if verificationIntroduced {
+		db.Model(&User{}).Where("email_verified_at IS NULL").Update("email_verified_at", time.Now())
+	}
+	db.AutoMigrate(&LoginThrottle{})
+	db.SetupJoinTable(&Tailor{}, "OutfitPrices", &TailorPrice{})
+	db.AutoMigrate(&Tailor{})
+	db.AutoMigrate(&Admin{})
+	db.AutoMigrate(&BalanceAdjustment{})
+	db.AutoMigrate(&TwoFactor{})
+	db.AutoMigrate(&RecoveryCode{})
+	db.AutoMigrate(&LedgerAccount{})
+	db.AutoMigrate(&JournalEntry{})
+	db.AutoMigrate(&Posting{})
+	db.AutoMigrate(&EscrowHold{})
+	db.AutoMigrate(&BankAccount{})
+	db.AutoMigrate(&WithdrawalRequest{})
+	db.AutoMigrate(&Transaction{})
+	for _, rename := range legacyStatuses {
+		db.Model(&Transaction{}).Where("status = ?", rename[0]).Update("status", rename[1])
+	}
+	db.AutoMigrate(&TopUp{})
+	db.AutoMigrate(&IdempotencyKey{})
+	db.AutoMigrate(&FeeRule{})
+	db.AutoMigrate(&StatusChange{})
+	db.AutoMigrate(&UsedQuote{})
 	
-}
\ No newline at end of file
+}
+
+// HashTailorPasswords rehashes tailor passwords that were seeded in plaintext.
+// Rows that already hold a bcrypt hash are left alone, so it is safe to rerun.
+func HashTailorPasswords() (int, error) {
+	db := database.GetInstance()
+
+	var tailors []Tailor
+	if err := db.Find(&tailors).Error; err != nil {
+		return 0, err
+	}
+
+	hashed := 0
+	for _, tailor := range tailors {
+		if _, err := bcrypt.Cost([]byte(tailor.Password)); err == nil {
+			continue
+		}
+
+		if err := tailor.SetPassword(tailor.Password); err != nil {
+			return hashed, err
+		}
+
+		if err := db.Model(&tailor).Update("password", tailor.Password).Error; err != nil {
+			return hashed, err
+		}
+		hashed++
+	}
+
+	return hashed, nil
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BankAccount is where a tailor's withdrawals are paid out.
+type BankAccount struct {
+	gorm.Model
+	TailorID      uint   `gorm:"index"`
+	BankCode      string `gorm:"size:16"`
+	AccountNumber string `gorm:"size:32"`
+	AccountHolder string
+}
+
+const (
+	WithdrawalPending   = "pending"
+	WithdrawalApproved  = "approved"
+	WithdrawalPaid      = "paid"
+	WithdrawalRejected  = "rejected"
+	WithdrawalCancelled = "cancelled"
+)
+
+// WithdrawalRequest is a tailor asking to be paid out. The amount leaves the
+// tailor's wallet when the request is filed and is held until it is paid,
+// or returned if the request is rejected or cancelled.
+type WithdrawalRequest struct {
+	gorm.Model
+	TailorID         uint `gorm:"index"`
+	BankAccountID    uint
+	BankAccount      BankAccount
+	Amount           int
+	Status           string `gorm:"size:16;index"`
+	ReviewedBy       *uint
+	ReviewedAt       *time.Time
+	RejectionReason  string
+	PaidAt           *time.Time
+	PaymentReference string
+}
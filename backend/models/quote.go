@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// UsedQuote records that a checkout quote has been placed. Its primary key
+// stops the same quote being placed twice, even by concurrent requests.
+type UsedQuote struct {
+	QuoteID   string `gorm:"primaryKey;size:32"`
+	Kind      string `gorm:"size:16"`
+	UserID    uint   `gorm:"index"`
+	CreatedAt time.Time
+}
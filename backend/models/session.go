@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is a login on one device. Access tokens carry the session ID and
+// generation, so revoking or rotating a session invalidates them at once.
+type Session struct {
+	gorm.Model
+	PrincipalID      uint `gorm:"index:idx_session_principal"`
+	PrincipalType    int  `gorm:"index:idx_session_principal"`
+	RefreshTokenHash string
+	Generation       int
+	UserAgent        string
+	IPAddress        string
+	LastUsedAt       time.Time
+	ExpiresAt        time.Time
+	RevokedAt        *time.Time
+}
+
+func (session *Session) IsActive() bool {
+	return session.RevokedAt == nil && time.Now().Before(session.ExpiresAt)
+}
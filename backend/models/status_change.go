@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// StatusChange is one step in a transaction's history: who moved it from
+// one status to the next, when, and why. FromStatus is empty for the
+// change that placed the transaction. ActorID is the user, tailor or admin
+// ID, and zero when the server acted on its own.
+type StatusChange struct {
+	ID            uint   `gorm:"primaryKey"`
+	TransactionID uint   `gorm:"index"`
+	FromStatus    string `gorm:"size:32"`
+	ToStatus      string `gorm:"size:32"`
+	Actor         string `gorm:"size:16"`
+	ActorID       uint
+	Note          string
+	CreatedAt     time.Time
+}
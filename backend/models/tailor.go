@@ -2,7 +2,9 @@ package model
 
 import (
 	"main/database"
+	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -16,6 +18,18 @@ type Tailor struct {
 	Money int
 	OutfitPrices []Outfit `gorm:"many2many:tailor_prices"`
 	Products []Product
+	SuspendedAt *time.Time
+	SuspendedReason string
+	Tier string `gorm:"size:16;default:standard"`
+}
+
+func (tailor *Tailor) SetPassword(password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	tailor.Password = string(hashedPassword)
+	return nil
 }
 
 type TailorPrice struct{
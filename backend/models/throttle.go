@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one account email or one
+// client IP. Subject is prefixed with what it counts, e.g. "user:a@b.com" or
+// "ip:10.0.0.2".
+type LoginThrottle struct {
+	Subject       string `gorm:"primaryKey;size:191"`
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TopUpPending = "pending"
+	TopUpPaid    = "paid"
+	TopUpFailed  = "failed"
+	TopUpExpired = "expired"
+)
+
+// TopUp is a wallet top-up collected by the payment provider. The wallet is
+// only credited once the provider's webhook reports it paid. A top-up
+// started at checkout then pays its Transactions from the wallet straight
+// away, using PromoCode.
+type TopUp struct {
+	gorm.Model
+	UserID               uint `gorm:"index"`
+	Amount               int
+	Method               string `gorm:"size:32"`
+	Provider             string `gorm:"size:32;index:idx_top_up_provider_reference"`
+	ProviderReference    string `gorm:"size:64;index:idx_top_up_provider_reference"`
+	Status               string `gorm:"size:16"`
+	VirtualAccountNumber string
+	CheckoutURL          string
+	QRISPayload          string        `gorm:"type:text"`
+	Transactions         []Transaction `gorm:"many2many:top_up_transactions"`
+	PromoCode            string
+	QuoteID              string `gorm:"size:32"`
+	ExpiresAt            *time.Time
+	PaidAt               *time.Time
+}
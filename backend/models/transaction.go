@@ -15,4 +15,47 @@ type Transaction struct {
 	Requests        []Request `gorm:"many2many:tran_requests"`
 	Status          string
 	TotalPrice		uint
+	// QuoteID is the checkout quote the transaction was priced by. Payment
+	// must present the same quote.
+	QuoteID string `gorm:"size:32;index"`
+	// Subtotal is the price of the items, without shipping and before the
+	// promo discount, and TailorTier the tailor's tier of the fee schedule,
+	// both as they were when the transaction was placed. The platform fee is
+	// charged on Subtotal at that tier's rate.
+	Subtotal   uint
+	TailorTier string `gorm:"size:16"`
+	// The platform fee taken when the payment was released to the tailor,
+	// and the rule and rate it was charged at. FeeRuleID is nil when no rule
+	// matched and the default rate applied.
+	FeeRuleID *uint
+	FeeRate   int
+	FeeAmount int
+	// CompletionNoticeAt is when both parties were told a delivered
+	// transaction is about to be finished automatically. It is cleared when
+	// the transaction leaves Delivered.
+	CompletionNoticeAt *time.Time
+}
+
+// Transaction statuses. Transactions start Pending Payment and become Paid
+// once paid; the tailor then accepts, makes and ships them until the buyer
+// confirms receipt. lifecycle defines who may move them between statuses.
+const (
+	StatusPendingPayment = "Pending Payment"
+	StatusPaid           = "Paid"
+	StatusAccepted       = "Accepted"
+	StatusInProgress     = "In Progress"
+	StatusShipped        = "Shipped"
+	StatusDelivered      = "Delivered"
+	StatusFinished       = "Finished"
+	StatusCancelled      = "Cancelled"
+	StatusDisputed       = "Disputed"
+)
+
+// legacyStatuses maps statuses stored before the lifecycle was defined to
+// their replacements.
+var legacyStatuses = [][2]string{
+	{"Unpaid", StatusPendingPayment},
+	{"Pending", StatusPaid},
+	{"Confirmed", StatusAccepted},
+	{"Shipping", StatusShipped},
 }
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TwoFactor holds a tailor's or admin's TOTP secret. EnabledAt stays nil
+// until the first code has been confirmed. LastStep is the time step of the
+// last accepted code, so a code can't be used twice.
+type TwoFactor struct {
+	gorm.Model
+	PrincipalID   uint `gorm:"uniqueIndex:idx_two_factor_principal"`
+	PrincipalType int  `gorm:"uniqueIndex:idx_two_factor_principal"`
+	Secret        string
+	EnabledAt     *time.Time
+	LastStep      int64
+}
+
+func (twoFactor *TwoFactor) IsEnabled() bool {
+	return twoFactor.EnabledAt != nil
+}
+
+type RecoveryCode struct {
+	gorm.Model
+	PrincipalID   uint `gorm:"index:idx_recovery_code_principal"`
+	PrincipalType int  `gorm:"index:idx_recovery_code_principal"`
+	CodeHash      string
+	UsedAt        *time.Time
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"regexp"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -20,6 +21,13 @@ type User struct {
 	Points int
 	Promos []Promo `gorm:"many2many:user_promos;references:PromoCode;joinReferences:PromoCode"`
 	Money int
+	EmailVerifiedAt *time.Time
+	SuspendedAt *time.Time
+	SuspendedReason string
+}
+
+func (user *User) IsEmailVerified() bool {
+	return user.EmailVerifiedAt != nil
 }
 
 func (user *User) SetPassword(password string) error {
@@ -0,0 +1,193 @@
+// Package payout takes tailor withdrawals from request to bank transfer.
+// Filing a request moves the amount from the tailor's wallet into pending
+// payouts; rejecting or cancelling it moves the money back, and marking it
+// paid sends it out of the ledger. Every step must run inside a DB
+// transaction.
+package payout
+
+import (
+	"errors"
+	"fmt"
+	"main/ledger"
+	model "main/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Limits bound the amounts a tailor may withdraw. Daily caps the total of
+// the requests filed in any 24 hours, leaving out rejected and cancelled
+// ones.
+type Limits struct {
+	Minimum int
+	Maximum int
+	Daily   int
+}
+
+var (
+	ErrBelowMinimum  = errors.New("payout: amount below minimum")
+	ErrAboveMaximum  = errors.New("payout: amount above maximum")
+	ErrDailyLimit    = errors.New("payout: daily limit reached")
+	ErrNoBankAccount = errors.New("payout: bank account not found")
+	ErrNotFound      = errors.New("payout: withdrawal request not found")
+	ErrWrongStatus   = errors.New("payout: withdrawal request is not in the right state")
+)
+
+// Request files a withdrawal of amount to one of the tailor's bank
+// accounts. ledger.ErrInsufficientFunds means the wallet can't cover it.
+func Request(tx *gorm.DB, tailorID, bankAccountID uint, amount int, limits Limits) (model.WithdrawalRequest, error) {
+	if amount < limits.Minimum {
+		return model.WithdrawalRequest{}, ErrBelowMinimum
+	}
+	if amount > limits.Maximum {
+		return model.WithdrawalRequest{}, ErrAboveMaximum
+	}
+
+	var bankAccount model.BankAccount
+	if tx.Where("id = ? AND tailor_id = ?", bankAccountID, tailorID).Limit(1).Find(&bankAccount).RowsAffected == 0 {
+		return model.WithdrawalRequest{}, ErrNoBankAccount
+	}
+
+	request := model.WithdrawalRequest{
+		TailorID:      tailorID,
+		BankAccountID: bankAccount.ID,
+		BankAccount:   bankAccount,
+		Amount:        amount,
+		Status:        model.WithdrawalPending,
+	}
+	if err := tx.Omit("BankAccount").Create(&request).Error; err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+
+	// Posting locks the tailor's wallet, so concurrent requests from the same
+	// tailor are counted one after another.
+	_, err := ledger.Transfer(tx, model.EntryWithdrawalHold, fmt.Sprintf("Withdrawal request %d", request.ID), ledger.TailorWallet(tailorID), ledger.PendingPayouts, amount)
+	if err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+
+	var filed int
+	if err := tx.Model(&model.WithdrawalRequest{}).
+		Where("tailor_id = ? AND status NOT IN ? AND created_at > ?", tailorID, []string{model.WithdrawalRejected, model.WithdrawalCancelled}, time.Now().Add(-24*time.Hour)).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&filed).Error; err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+	if filed > limits.Daily {
+		return model.WithdrawalRequest{}, ErrDailyLimit
+	}
+
+	return request, nil
+}
+
+// Approve clears a pending request for payment. The money stays in pending
+// payouts until the transfer is made.
+func Approve(tx *gorm.DB, id, adminID uint) (model.WithdrawalRequest, error) {
+	request, err := lock(tx, id, model.WithdrawalPending)
+	if err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+
+	now := time.Now()
+	request.Status = model.WithdrawalApproved
+	request.ReviewedBy = &adminID
+	request.ReviewedAt = &now
+
+	err = tx.Model(&request).Updates(map[string]interface{}{
+		"status":      request.Status,
+		"reviewed_by": request.ReviewedBy,
+		"reviewed_at": request.ReviewedAt,
+	}).Error
+	return request, err
+}
+
+// Reject turns down a pending or approved request and returns the money to
+// the tailor's wallet.
+func Reject(tx *gorm.DB, id, adminID uint, reason string) (model.WithdrawalRequest, error) {
+	request, err := lock(tx, id, model.WithdrawalPending, model.WithdrawalApproved)
+	if err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+
+	if err := release(tx, request); err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+
+	now := time.Now()
+	request.Status = model.WithdrawalRejected
+	request.ReviewedBy = &adminID
+	request.ReviewedAt = &now
+	request.RejectionReason = reason
+
+	err = tx.Model(&request).Updates(map[string]interface{}{
+		"status":           request.Status,
+		"reviewed_by":      request.ReviewedBy,
+		"reviewed_at":      request.ReviewedAt,
+		"rejection_reason": request.RejectionReason,
+	}).Error
+	return request, err
+}
+
+// Cancel withdraws a request the tailor filed, as long as no admin has
+// reviewed it yet.
+func Cancel(tx *gorm.DB, id uint) (model.WithdrawalRequest, error) {
+	request, err := lock(tx, id, model.WithdrawalPending)
+	if err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+
+	if err := release(tx, request); err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+
+	request.Status = model.WithdrawalCancelled
+	err = tx.Model(&request).Update("status", request.Status).Error
+	return request, err
+}
+
+// MarkPaid records that an approved request has been transferred to the
+// tailor's bank, under the bank's transfer reference.
+func MarkPaid(tx *gorm.DB, id uint, reference string) (model.WithdrawalRequest, error) {
+	request, err := lock(tx, id, model.WithdrawalApproved)
+	if err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+
+	_, err = ledger.Transfer(tx, model.EntryWithdrawal, fmt.Sprintf("Withdrawal request %d paid", request.ID), ledger.PendingPayouts, ledger.External, request.Amount)
+	if err != nil {
+		return model.WithdrawalRequest{}, err
+	}
+
+	now := time.Now()
+	request.Status = model.WithdrawalPaid
+	request.PaidAt = &now
+	request.PaymentReference = reference
+
+	err = tx.Model(&request).Updates(map[string]interface{}{
+		"status":            request.Status,
+		"paid_at":           request.PaidAt,
+		"payment_reference": request.PaymentReference,
+	}).Error
+	return request, err
+}
+
+// lock loads the request for update and checks it is in one of statuses.
+func lock(tx *gorm.DB, id uint, statuses ...string) (model.WithdrawalRequest, error) {
+	var request model.WithdrawalRequest
+	if tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Limit(1).Find(&request).RowsAffected == 0 {
+		return model.WithdrawalRequest{}, ErrNotFound
+	}
+
+	for _, status := range statuses {
+		if request.Status == status {
+			return request, nil
+		}
+	}
+	return request, ErrWrongStatus
+}
+
+func release(tx *gorm.DB, request model.WithdrawalRequest) error {
+	_, err := ledger.Transfer(tx, model.EntryWithdrawalRelease, fmt.Sprintf("Withdrawal request %d returned", request.ID), ledger.PendingPayouts, ledger.TailorWallet(request.TailorID), request.Amount)
+	return err
+}
@@ -0,0 +1,125 @@
+package qris
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		data string
+		want uint16
+	}{
+		// The standard check value for CRC-16/CCITT-FALSE.
+		{"123456789", 0x29B1},
+		{"", 0xFFFF},
+		{"A", 0xB915},
+	}
+
+	for _, tt := range tests {
+		if got := CRC16(tt.data); got != tt.want {
+			t.Errorf("CRC16(%q) = %04X, want %04X", tt.data, got, tt.want)
+		}
+	}
+}
+
+var testMerchant = Merchant{
+	ID:           "ID1000000000001",
+	Name:         "TailorTech",
+	City:         "JAKARTA",
+	PostalCode:   "10110",
+	CategoryCode: "5699",
+}
+
+func TestPayload(t *testing.T) {
+	got, err := Payload(testMerchant, Payment{Amount: 150000, BillNumber: "topup-42", Reference: "mock-abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "000201" + "010212" +
+		"5144" + "0014ID.CO.QRIS.WWW" + "0215ID1000000000001" + "0303UMI" +
+		"52045699" + "5303360" + "5406150000" + "5802ID" +
+		"5910TailorTech" + "6007JAKARTA" + "610510110" +
+		"6224" + "0108topup-42" + "0508mock-abc" +
+		"63047AF8"
+	if got != want {
+		t.Errorf("Payload() =\n  %s\nwant\n  %s", got, want)
+	}
+	if !Verify(got) {
+		t.Errorf("Verify(Payload()) = false")
+	}
+}
+
+func TestPayloadRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		merchant Merchant
+		payment  Payment
+	}{
+		{"zero amount", testMerchant, Payment{Amount: 0}},
+		{"long merchant name", Merchant{ID: "ID1", Name: strings.Repeat("N", 26), City: "JAKARTA"}, Payment{Amount: 1}},
+		{"long city", Merchant{ID: "ID1", Name: "TailorTech", City: strings.Repeat("C", 16)}, Payment{Amount: 1}},
+	}
+
+	for _, tt := range tests {
+		if _, err := Payload(tt.merchant, tt.payment); err == nil {
+			t.Errorf("%s: Payload() error = nil", tt.name)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields [][2]string
+		want   string
+	}{
+		{"single digit length", [][2]string{{"01", "12"}}, "010212"},
+		{"ten characters", [][2]string{{"59", "TailorTech"}}, "5910TailorTech"},
+		{"longest value", [][2]string{{"05", strings.Repeat("x", 99)}}, "0599" + strings.Repeat("x", 99)},
+		{"empty fields are left out", [][2]string{{"01", "a"}, {"02", ""}, {"03", "bc"}}, "0101a0302bc"},
+	}
+
+	for _, tt := range tests {
+		got, err := encode(tt.fields)
+		if err != nil {
+			t.Errorf("%s: encode() error = %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: encode() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := encode([][2]string{{"05", strings.Repeat("x", 100)}}); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("encode() of 100 characters error = %v, want ErrInvalidPayload", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	payload, err := Payload(testMerchant, Payment{Amount: 25000, BillNumber: "topup-7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		want    bool
+	}{
+		{"as built", payload, true},
+		{"lower-case checksum", payload[:len(payload)-4] + strings.ToLower(payload[len(payload)-4:]), true},
+		{"amount changed", strings.Replace(payload, "540525000", "540599000", 1), false},
+		{"checksum changed", payload[:len(payload)-4] + "0000", false},
+		{"no checksum tag", payload[:len(payload)-8], false},
+		{"too short", "6304", false},
+	}
+
+	for _, tt := range tests {
+		if got := Verify(tt.payload); got != tt.want {
+			t.Errorf("%s: Verify() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package settlement ends a transaction's life, moving its status and the
+// payment held in escrow together. Both steps must run inside a DB
+// transaction.
+package settlement
+
+import (
+	"errors"
+	"main/escrow"
+	"main/lifecycle"
+	model "main/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrNoTailorPrice = errors.New("settlement: tailor price not found for request")
+
+// Points works out the points the buyer earns when the transaction, which
+// must have its Requests loaded, is finished: one for every 15 of the
+// tailor's price for each custom request.
+func Points(tx *gorm.DB, transaction model.Transaction) (int, error) {
+	points := 0
+	for _, request := range transaction.Requests {
+		var tailorPrice model.TailorPrice
+		if tx.Where("tailor_id = ? AND outfit_id = ?", transaction.TailorID, request.RequestType).Limit(1).Find(&tailorPrice).RowsAffected == 0 {
+			return 0, ErrNoTailorPrice
+		}
+		points += int(tailorPrice.Price) / 15
+	}
+	return points, nil
+}
+
+// Finish marks the transaction finished, releases the escrowed payment to
+// the tailor and awards the buyer's points, all or nothing.
+func Finish(tx *gorm.DB, transactionID uint, by lifecycle.By, note string, pointsAwarded int) error {
+	previous, err := lifecycle.Apply(tx, transactionID, model.StatusFinished, by, note)
+	if err != nil {
+		return err
+	}
+
+	if _, err := escrow.Release(tx, transactionID); err != nil {
+		return err
+	}
+
+	if pointsAwarded > 0 {
+		return tx.Model(&model.User{}).Where("id = ?", previous.UserID).Update("points", gorm.Expr("points + ?", pointsAwarded)).Error
+	}
+	return nil
+}
+
+// Cancel marks the transaction cancelled, refunds the payment held in
+// escrow to the buyer's wallet if it was paid, and puts its products back
+// on sale.
+func Cancel(tx *gorm.DB, transactionID uint, by lifecycle.By, note string) error {
+	previous, err := lifecycle.Apply(tx, transactionID, model.StatusCancelled, by, note)
+	if err != nil {
+		return err
+	}
+
+	if previous.Status != model.StatusPendingPayment {
+		if _, err := escrow.Refund(tx, transactionID); err != nil {
+			return err
+		}
+	}
+
+	products := tx.Table("tran_products").Select("product_id").Where("transaction_id = ?", transactionID)
+	return tx.Model(&model.Product{}).Where("id IN (?)", products).Update("is_active", true).Error
+}
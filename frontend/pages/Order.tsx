@@ -105,7 +105,7 @@ const OrderScreen: React.FC = () => {
         </View>
       ))}
       <Text style={styles.productStatus}>Status: {item.Status}</Text>
-      {item.Status === 'Shipping' && (
+      {(item.Status === 'Shipped' || item.Status === 'Delivered') && (
         <TouchableOpacity 
           style={styles.confirmButton} 
           onPress={() => handleOrderReceived(item.ID)}
@@ -133,7 +133,7 @@ const OrderScreen: React.FC = () => {
         </View>
       </View>
       <Text style={styles.productStatus}>Status: {item.Status}</Text>
-      {item.Status === 'Shipping' && (
+      {(item.Status === 'Shipped' || item.Status === 'Delivered') && (
         <TouchableOpacity 
           style={styles.confirmButton} 
           onPress={() => handleRequestReceived(item.ID)}
@@ -67,41 +67,45 @@ const OrderPaymentScreen: React.FC = () => {
   }, [couponCode, userCoupons]);
 
   const handlePayment = async () => {
-    const totalAmount = Math.max(TotalPrice - discount + shippingFee, 0);
+    try {
+      const quoteResponse = await axios.post('http://localhost:8000/checkout/quote', {
+        userId: user.ID,
+        productIds: Products.map(item => item.ID),
+        promoCode: couponCode,
+      });
+      const totalAmount = quoteResponse.data.quote.Total;
 
-    if (user.Money < totalAmount) {
-      setErrorMessage('Insufficient balance to complete the payment.');
-      return;
-    }
+      if (user.Money < totalAmount) {
+        setErrorMessage('Insufficient balance to complete the payment.');
+        return;
+      }
+
+      const requestEndpoint = 'http://localhost:8000/orders/create';
+      const requestData = {
+        UserID: user.ID,
+        Name: user.Name,
+        QuoteToken: quoteResponse.data.token,
+      };
+      const requestResponse = await axios.post(requestEndpoint, requestData);
+
+      if (requestResponse.status !== 201) {
+        console.error(`Unexpected response status: ${requestResponse.status}`);
+        setErrorMessage('Failed to create request');
+        return;
+      }
 
-    try {
       const paymentEndpoint = 'http://localhost:8000/payment';
       const paymentData = {
         UserID: user.ID,
-        TotalAmount: totalAmount,
-        PromoCode: couponCode,
+        TransactionIDs: requestResponse.data.TransactionIDs,
+        QuoteToken: quoteResponse.data.token,
         PaymentMethod: 'TailorPay',
       };
 
       const paymentResponse = await axios.post(paymentEndpoint, paymentData);
 
       if (paymentResponse.status === 200) {
-        const requestEndpoint = 'http://localhost:8000/orders/create';
-        const requestData = {
-          UserID: user.ID,
-          Name: user.Name,
-          ProductIDs: Products.map(item => item.ID),
-          Status: 'Pending',
-          TotalPrice: totalAmount,
-        };
-        const requestResponse = await axios.post(requestEndpoint, requestData);
-
-        if (requestResponse.status === 201) {
-          navigation.navigate('OrderSent');
-        } else {
-          console.error(`Unexpected response status: ${requestResponse.status}`);
-          setErrorMessage('Failed to create request');
-        }
+        navigation.navigate('OrderSent');
       } else {
         setErrorMessage('Failed to process payment');
       }
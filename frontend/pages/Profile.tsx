@@ -70,11 +70,14 @@ const ProfileScreen = () => {
     try {
       const response = await axios.post(`http://localhost:8000/users/topup/${user.ID}`, {
         amount: amount,
+        paymentMethod: 'VirtualAccount',
       }, {
         withCredentials: true,
       });
 
-      if (response.status === 200) {
+      if (response.status === 201) {
+        const topUp = response.data.topUp;
+        Alert.alert("Top Up", `Transfer IDR ${topUp.Amount}K to virtual account ${topUp.VirtualAccountNumber}. Your balance updates once the payment arrives.`)
         fetchUserDetails(); 
         setIsModalVisible(false); 
         setTopUpAmount(''); 
@@ -58,61 +58,64 @@ const RequestPaymentScreen: React.FC = () => {
   }, [couponCode, userCoupons]);
 
   const handlePayment = async () => {
-    const totalAmount = Math.max(basePrice - discount + shippingFee, 0);
+    try {
+      const quoteResponse = await axios.post('http://localhost:8000/checkout/quote', {
+        userId: user.ID,
+        tailorId: tailorId,
+        requestType: selectedType,
+        promoCode: couponCode,
+      });
+      const totalAmount = quoteResponse.data.quote.Total;
 
-    if (user.Money < totalAmount) {
-      Alert.alert('Error', 'Insufficient balance to complete the payment.');
-      return;
-    }
+      if (user.Money < totalAmount) {
+        Alert.alert('Error', 'Insufficient balance to complete the payment.');
+        return;
+      }
+
+      const requestEndpoint = 'http://localhost:8000/requests/create';
+      const requestData = {
+        UserID: user.ID,
+        Name: user.Name,
+        Desc: description,
+        QuoteToken: quoteResponse.data.token,
+      };
+      const requestResponse = await axios.post(requestEndpoint, requestData);
+
+      if (requestResponse.status !== 201) {
+        console.error(`Unexpected response status: ${requestResponse.status}`);
+        setErrorMessage('Failed to create request');
+        return;
+      }
+
+      const requestId = requestResponse.data.ID;
+      let endpoint;
+      if (selectedType === 'TOTE BAGS') {
+        endpoint = `http://localhost:8000/measurements/totebags`;
+      } else {
+        endpoint = `http://localhost:8000/measurements/${selectedType.toLowerCase()}`;
+      }
+      const response = await axios.post(endpoint, {
+        ...measurements,
+        RequestID: requestId,
+      });
+      if (response.status !== 201) {
+        console.error(`Unexpected response status: ${response.status}`);
+        setErrorMessage('Failed to save measurements');
+        return;
+      }
 
-    try {
       const paymentEndpoint = 'http://localhost:8000/payment';
       const paymentData = {
         UserId: user.ID,
-        TotalAmount: totalAmount,
-        PromoCode: couponCode,
+        TransactionIDs: [requestResponse.data.TransactionID],
+        QuoteToken: quoteResponse.data.token,
         PaymentMethod: 'TailorPay',
       };
 
       const paymentResponse = await axios.post(paymentEndpoint, paymentData);
 
       if (paymentResponse.status === 200) {
-
-        const requestEndpoint = 'http://localhost:8000/requests/create';
-        const requestData = {
-          UserID: user.ID,
-          Name: user.Name,
-          Desc: description,
-          Price: basePrice,
-          RequestType: selectedType,
-          TailorID: tailorId,
-          Status: 'Pending',
-          TotalPrice: totalAmount,
-        };
-        const requestResponse = await axios.post(requestEndpoint, requestData);
-
-        if (requestResponse.status === 201) {
-          const requestId = requestResponse.data.ID;
-          let endpoint;
-          if (selectedType === 'TOTE BAGS') {
-            endpoint = `http://localhost:8000/measurements/totebags`;
-          } else {
-            endpoint = `http://localhost:8000/measurements/${selectedType.toLowerCase()}`;
-          }
-          const response = await axios.post(endpoint, {
-            ...measurements,
-            RequestID: requestId,
-          });
-          if (response.status === 201) {
-            navigation.navigate('RequestSent');
-          } else {
-            console.error(`Unexpected response status: ${response.status}`);
-            setErrorMessage('Failed to save measurements');
-          }
-        } else {
-          console.error(`Unexpected response status: ${requestResponse.status}`);
-          setErrorMessage('Failed to create request');
-        }
+        navigation.navigate('RequestSent');
       } else {
         setErrorMessage('Failed to process payment');
       }
@@ -102,20 +102,28 @@ const TailorOrderScreen: React.FC = () => {
         </View>
       ))}
       <Text style={styles.status}>Status: {item.Status}</Text>
-      {item.Status === 'Pending' && (
+      {item.Status === 'Paid' && (
         <TouchableOpacity 
           style={styles.statusButton} 
-          onPress={() => fetchStatusUpdate(item.ID, 'Confirmed', 'order')}
+          onPress={() => fetchStatusUpdate(item.ID, 'Accepted', 'order')}
         >
           <Text style={styles.statusButtonText}>Confirm</Text>
         </TouchableOpacity>
       )}
-      {item.Status === 'Confirmed' && (
+      {item.Status === 'Accepted' && (
         <TouchableOpacity 
           style={styles.statusButton} 
-          onPress={() => fetchStatusUpdate(item.ID, 'Shipping', 'order')}
+          onPress={() => fetchStatusUpdate(item.ID, 'Shipped', 'order')}
         >
-          <Text style={styles.statusButtonText}>Shipping</Text>
+          <Text style={styles.statusButtonText}>Shipped</Text>
+        </TouchableOpacity>
+      )}
+      {item.Status === 'Shipped' && (
+        <TouchableOpacity 
+          style={styles.statusButton} 
+          onPress={() => fetchStatusUpdate(item.ID, 'Delivered', 'order')}
+        >
+          <Text style={styles.statusButtonText}>Delivered</Text>
         </TouchableOpacity>
       )}
     </View>
@@ -144,15 +152,15 @@ const TailorOrderScreen: React.FC = () => {
         </View>
       </View>
       <Text style={styles.status}>Status: {item.Status}</Text>
-      {item.Status === 'Pending' && (
+      {item.Status === 'Paid' && (
         <TouchableOpacity 
           style={styles.statusButton} 
-          onPress={() => fetchStatusUpdate(item.ID, 'Confirmed', 'request')}
+          onPress={() => fetchStatusUpdate(item.ID, 'Accepted', 'request')}
         >
           <Text style={styles.statusButtonText}>Confirm</Text>
         </TouchableOpacity>
       )}
-      {item.Status === 'Confirmed' && (
+      {item.Status === 'Accepted' && (
         <TouchableOpacity 
           style={styles.statusButton} 
           onPress={() => fetchStatusUpdate(item.ID, 'In Progress', 'request')}
@@ -163,9 +171,17 @@ const TailorOrderScreen: React.FC = () => {
       {item.Status === 'In Progress' && (
         <TouchableOpacity 
           style={styles.statusButton} 
-          onPress={() => fetchStatusUpdate(item.ID, 'Shipping', 'request')}
+          onPress={() => fetchStatusUpdate(item.ID, 'Shipped', 'request')}
+        >
+          <Text style={styles.statusButtonText}>Shipped</Text>
+        </TouchableOpacity>
+      )}
+      {item.Status === 'Shipped' && (
+        <TouchableOpacity 
+          style={styles.statusButton} 
+          onPress={() => fetchStatusUpdate(item.ID, 'Delivered', 'request')}
         >
-          <Text style={styles.statusButtonText}>Shipping</Text>
+          <Text style={styles.statusButtonText}>Delivered</Text>
         </TouchableOpacity>
       )}
     </View>
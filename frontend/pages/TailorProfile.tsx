@@ -64,8 +64,8 @@ const TailorProfileScreen: React.FC = () => {
         withCredentials: true,
       });
 
-      if (response.status === 200) {
-        Alert.alert("Success", "Withdrawal Successful!")
+      if (response.status === 201) {
+        Alert.alert("Success", "Withdrawal requested. It will be paid to your bank account once approved.")
         fetchTailorDetails(); 
         setIsModalVisible(false); 
         setWithdrawalAmount(''); 
@@ -74,6 +74,9 @@ const TailorProfileScreen: React.FC = () => {
       }
     } catch (error) {
       console.error('Withdrawal error:', error);
+      if (axios.isAxiosError(error) && error.response?.data?.error) {
+        Alert.alert("Error", error.response.data.error)
+      }
     }
   };
 